@@ -0,0 +1,277 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultDomain is the domain used by bundles when none is given, as in
+// GNU gettext.
+const DefaultDomain = "messages"
+
+// emptyCatalog is used for lookups in missing catalogs. It is never
+// modified.
+var emptyCatalog = NewCatalog()
+
+// NewBundle returns a new bundle using the given default domain. If domain
+// is empty, DefaultDomain is used.
+func NewBundle(domain string) *Bundle {
+	if domain == "" {
+		domain = DefaultDomain
+	}
+	return &Bundle{
+		DefaultDomain: domain,
+		catalogs:      map[string]map[string]*Catalog{},
+	}
+}
+
+// Bundle stores the catalogs of several locales and domains.
+//
+// Lookup methods take a locale and a domain. Locales are BCP 47 tags or
+// POSIX names, canonicalized as done by ParseLocale, so "pt-BR" and
+// "pt_BR.UTF-8" name the same catalogs. An empty domain means the default
+// domain of the bundle. If the bundle has no catalog for them, the
+// untranslated strings are returned.
+//
+// A bundle is safe for concurrent use, so catalogs can be replaced, as done
+// by Reloader, while lookups run. DefaultDomain must not be modified while
+// the bundle is in use by other goroutines.
+type Bundle struct {
+	DefaultDomain string // domain used when none is given
+	mu            sync.RWMutex
+	catalogs      map[string]map[string]*Catalog // catalogs by locale and domain
+}
+
+// Load loads the catalogs found in the GNU directory layout under root:
+// <root>/<locale>/LC_MESSAGES/<domain>.mo, or .po. If both files exist for
+// a domain, the MO file is used. Catalogs loaded before for the same locale
+// and domain are replaced.
+//
+// All files are read, even if some fail. Errors found reading a file are
+// returned together in a MultiError, as *os.PathError values with the file
+// name. Files with invalid messages, such as duplicated msgids, are still
+// loaded with their valid messages, as done by Catalog.ReadPo; files that
+// can't be read at all are skipped.
+func (b *Bundle) Load(root string) error {
+	files, err := catalogFiles(root)
+	if err != nil {
+		return err
+	}
+	var errs MultiError
+	for _, file := range files {
+		c, err := loadCatalog(file.path)
+		if c != nil {
+			b.AddCatalog(file.locale, file.domain, c)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// catalogFile is a catalog file found in the GNU directory layout.
+type catalogFile struct {
+	locale string
+	domain string
+	path   string
+}
+
+// catalogFiles returns the catalog files found in the GNU directory layout
+// under root, sorted by path. If both a MO and a PO file exist for a
+// domain, only the MO file is returned.
+func catalogFiles(root string) ([]catalogFile, error) {
+	locales, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var catalogs []catalogFile
+	for _, locale := range locales {
+		if !locale.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, locale.Name(), "LC_MESSAGES")
+		files, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Collect the file for each domain, preferring MO files. Files are
+		// sorted by name, so domains are added in order.
+		var domains []string
+		names := map[string]string{}
+		for _, file := range files {
+			name := file.Name()
+			ext := filepath.Ext(name)
+			if file.IsDir() || ext != ".mo" && ext != ".po" {
+				continue
+			}
+			domain := strings.TrimSuffix(name, ext)
+			if _, ok := names[domain]; !ok {
+				domains = append(domains, domain)
+				names[domain] = name
+			} else if ext == ".mo" {
+				names[domain] = name
+			}
+		}
+		for _, domain := range domains {
+			catalogs = append(catalogs, catalogFile{
+				locale: locale.Name(),
+				domain: domain,
+				path:   filepath.Join(dir, names[domain]),
+			})
+		}
+	}
+	return catalogs, nil
+}
+
+// loadCatalog reads a catalog from a MO or PO file. If the file has invalid
+// messages, the catalog with the valid ones is returned along with the
+// error.
+func loadCatalog(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c := NewCatalog()
+	if filepath.Ext(path) == ".mo" {
+		err = c.ReadMo(f)
+	} else {
+		err = c.ReadPo(f)
+	}
+	if err != nil {
+		if _, ok := err.(MultiError); !ok {
+			c = nil
+		}
+		return c, &os.PathError{Op: "load", Path: path, Err: err}
+	}
+	return c, nil
+}
+
+// AddCatalog sets the catalog for the given locale and domain, replacing
+// the existing one, if any. An empty domain means the default domain.
+func (b *Bundle) AddCatalog(locale, domain string, c *Catalog) {
+	locale = canonicalLocale(locale)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	domains, ok := b.catalogs[locale]
+	if !ok {
+		domains = map[string]*Catalog{}
+		b.catalogs[locale] = domains
+	}
+	domains[b.domain(domain)] = c
+}
+
+// Catalog returns the catalog for the given locale and domain, or nil if
+// there is none. An empty domain means the default domain.
+func (b *Bundle) Catalog(locale, domain string) *Catalog {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.catalogs[canonicalLocale(locale)][b.domain(domain)]
+}
+
+// Locales returns the locales that have catalogs, in canonical form and
+// sorted.
+func (b *Bundle) Locales() []string {
+	var locales []string
+	b.mu.RLock()
+	for locale := range b.catalogs {
+		locales = append(locales, locale)
+	}
+	b.mu.RUnlock()
+	sort.Strings(locales)
+	return locales
+}
+
+// Domains returns the domains that have catalogs for the given locale,
+// sorted.
+func (b *Bundle) Domains(locale string) []string {
+	var domains []string
+	b.mu.RLock()
+	for domain := range b.catalogs[canonicalLocale(locale)] {
+		domains = append(domains, domain)
+	}
+	b.mu.RUnlock()
+	sort.Strings(domains)
+	return domains
+}
+
+// Translator returns a translator that looks up messages in the catalogs
+// of the given locales and domain, in order. Each locale is followed by its
+// parents, so "pt-BR", "en" falls back to pt-BR, pt and en. Locales without
+// a catalog for the domain are skipped. An empty domain means the default
+// domain.
+func (b *Bundle) Translator(domain string, locales ...string) *Translator {
+	t := NewTranslator()
+	seen := map[string]bool{}
+	for _, locale := range locales {
+		chain := []string{canonicalLocale(locale)}
+		if l, err := ParseLocale(locale); err == nil {
+			for _, p := range l.Parents() {
+				chain = append(chain, p.String())
+			}
+		}
+		for _, locale := range chain {
+			if c := b.Catalog(locale, domain); c != nil && !seen[locale] {
+				seen[locale] = true
+				t.Add(locale, c)
+			}
+		}
+	}
+	return t
+}
+
+// Dgettext is like Catalog.Singular, using the catalog of the given locale
+// and domain.
+func (b *Bundle) Dgettext(locale, domain, msgid string, args ...interface{}) string {
+	return b.lookupCatalog(locale, domain).Singular(msgid, args...)
+}
+
+// Dngettext is like Catalog.Plural, using the catalog of the given locale
+// and domain.
+func (b *Bundle) Dngettext(locale, domain, msgid, msgidPlural string, n int, args ...interface{}) string {
+	return b.lookupCatalog(locale, domain).Plural(msgid, msgidPlural, n, args...)
+}
+
+// Dpgettext is like Catalog.ContextSingular, using the catalog of the
+// given locale and domain.
+func (b *Bundle) Dpgettext(locale, domain, ctxt, msgid string, args ...interface{}) string {
+	return b.lookupCatalog(locale, domain).ContextSingular(ctxt, msgid, args...)
+}
+
+// Dnpgettext is like Catalog.ContextPlural, using the catalog of the given
+// locale and domain.
+func (b *Bundle) Dnpgettext(locale, domain, ctxt, msgid, msgidPlural string, n int, args ...interface{}) string {
+	return b.lookupCatalog(locale, domain).ContextPlural(ctxt, msgid, msgidPlural, n, args...)
+}
+
+// lookupCatalog returns the catalog for the given locale and domain, or an
+// empty catalog if there is none.
+func (b *Bundle) lookupCatalog(locale, domain string) *Catalog {
+	if c := b.Catalog(locale, domain); c != nil {
+		return c
+	}
+	return emptyCatalog
+}
+
+// domain returns the given domain, or the default one if it is empty.
+func (b *Bundle) domain(domain string) string {
+	if domain == "" {
+		return b.DefaultDomain
+	}
+	return domain
+}
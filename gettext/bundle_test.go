@@ -0,0 +1,155 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var germanPoData = `msgid ""
+msgstr ""
+"Language: de\n"
+"Content-Type: text/plain; charset=UTF-8\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+msgid "Open"
+msgstr "Öffnen"
+
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d Datei"
+msgstr[1] "%d Dateien"
+`
+
+// writeBundleFile writes a file under root, creating its directories.
+func writeBundleFile(t *testing.T, root, name string, data []byte) {
+	path := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	root, err := ioutil.TempDir("", "testBundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(germanPoData)); err != nil {
+		t.Fatal(err)
+	}
+	mo := new(bytes.Buffer)
+	if err := WriteMo(mo, c.Iter()); err != nil {
+		t.Fatal(err)
+	}
+	writeBundleFile(t, root, "pl/LC_MESSAGES/messages.po", []byte(polishPoData))
+	writeBundleFile(t, root, "de/LC_MESSAGES/messages.mo", mo.Bytes())
+	writeBundleFile(t, root, "de/LC_MESSAGES/errors.po", []byte(polishPoData))
+	// The MO file is preferred over the invalid PO file.
+	writeBundleFile(t, root, "de/LC_MESSAGES/messages.po", []byte("invalid"))
+	writeBundleFile(t, root, "de/LC_MESSAGES/README", []byte("ignored"))
+	writeBundleFile(t, root, "fr/README", []byte("ignored"))
+
+	b := NewBundle("")
+	if err := b.Load(root); err != nil {
+		t.Fatal(err)
+	}
+	if locales := b.Locales(); !reflect.DeepEqual(locales, []string{"de", "pl"}) {
+		t.Errorf("Expected locales [de pl], got %v.", locales)
+	}
+	if domains := b.Domains("de"); !reflect.DeepEqual(domains, []string{"errors", "messages"}) {
+		t.Errorf("Expected domains [errors messages], got %v.", domains)
+	}
+	if b.Catalog("pl", "") == nil || b.Catalog("pl", "messages") != b.Catalog("pl", "") {
+		t.Errorf("Expected the catalog of the default domain.")
+	}
+	if b.Catalog("fr", "") != nil || b.Catalog("pl", "errors") != nil {
+		t.Errorf("Expected no catalog.")
+	}
+	tests := []struct {
+		got, expected string
+	}{
+		{b.Dgettext("de", "", "Open"), "Öffnen"},
+		{b.Dgettext("de", "messages", "Open"), "Öffnen"},
+		{b.Dgettext("de", "errors", "Open"), "Otwórz"},
+		{b.Dgettext("pl", "", "Open"), "Otwórz"},
+		{b.Dgettext("PL.UTF-8", "", "Open"), "Otwórz"},
+		{b.Dgettext("fr", "", "Open"), "Open"},
+		{b.Dgettext("pl", "missing", "Missing %d", 3), "Missing 3"},
+		{b.Dngettext("de", "", "%d file", "%d files", 2, 2), "2 Dateien"},
+		{b.Dngettext("pl", "", "%d file", "%d files", 3, 3), "3 pliki"},
+		{b.Dngettext("fr", "", "%d file", "%d files", 3, 3), "3 files"},
+		{b.Dpgettext("pl", "", "door", "Open"), "Otwarte"},
+		{b.Dpgettext("de", "", "door", "Open"), "Open"},
+		{b.Dnpgettext("pl", "", "disk", "%d file", "%d files", 5, 5), "5 plików na dysku"},
+	}
+	for _, test := range tests {
+		if test.got != test.expected {
+			t.Errorf("Expected %q, got %q.", test.expected, test.got)
+		}
+	}
+
+	custom := NewCatalog()
+	b.AddCatalog("fr", "", custom)
+	if b.Catalog("fr", DefaultDomain) != custom {
+		t.Errorf("Expected the added catalog.")
+	}
+}
+
+func TestBundleLoadErrors(t *testing.T) {
+	root, err := ioutil.TempDir("", "testBundleLoadErrors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	b := NewBundle("app")
+	if err := b.Load(filepath.Join(root, "missing")); !os.IsNotExist(err) {
+		t.Errorf("Expected a not exist error, got %v.", err)
+	}
+	// All files are loaded; invalid messages are skipped, and unreadable
+	// files are reported. Unsupported charsets are not errors.
+	writeBundleFile(t, root, "de/LC_MESSAGES/app.po", []byte(germanPoData+"\nmsgid \"Open\"\nmsgstr \"Offen\"\n"))
+	writeBundleFile(t, root, "fr/LC_MESSAGES/app.po", []byte("msgid \"Open\"\nmsgstr \"Ouvrir\"\n"))
+	writeBundleFile(t, root, "ja/LC_MESSAGES/app.po", []byte(eucJPPoData))
+	writeBundleFile(t, root, "pl/LC_MESSAGES/app.mo", []byte("invalid"))
+	err = b.Load(root)
+	errs, ok := err.(MultiError)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("Expected a MultiError with two errors, got %v.", err)
+	}
+	paths := []string{
+		filepath.Join(root, "de", "LC_MESSAGES", "app.po"),
+		filepath.Join(root, "pl", "LC_MESSAGES", "app.mo"),
+	}
+	for i, path := range paths {
+		if e, ok := errs[i].(*os.PathError); !ok || e.Path != path {
+			t.Errorf("Expected a path error for %s, got %v.", path, errs[i])
+		}
+	}
+	if got := b.Dgettext("de", "", "Open"); got != "Öffnen" {
+		t.Errorf("Expected %q, got %q.", "Öffnen", got)
+	}
+	if got := b.Dgettext("fr", "", "Open"); got != "Ouvrir" {
+		t.Errorf("Expected %q, got %q.", "Ouvrir", got)
+	}
+	if got := b.Dgettext("ja", "", "Open"); got != "\xb3\xab\xa4\xaf" {
+		t.Errorf("Expected %q, got %q.", "\xb3\xab\xa4\xaf", got)
+	}
+	if c := b.Catalog("pl", ""); c != nil {
+		t.Errorf("Expected no catalog for an unreadable file.")
+	}
+}
@@ -9,57 +9,248 @@ import (
 	"io"
 	"net/textproto"
 	"sort"
+	"sync"
 )
 
-// TODO: plural rules interface
-// TODO: catalog methods: ContextSingular(), Plural(), ContextPlural()...
-
-// ----------------------------------------------------------------------------
-
 // NewCatalog returns a new catalog instance.
 func NewCatalog() *Catalog {
 	return &Catalog{
-		msgs: map[string]*Message{},
+		msgs:    map[string]*Message{},
+		entries: map[msgKey]*entry{},
 	}
 }
 
 // Catalog stores translations.
+//
+// By default, invalid messages found when reading files, such as duplicated
+// keys or headers, are skipped and reported together in a MultiError once
+// all valid messages are added. If Strict is set, reading stops at the first
+// invalid message instead.
+//
+// A catalog is safe for concurrent use: lookups can run while messages are
+// read into it. Header and Strict must not be modified while the catalog
+// is in use by other goroutines.
 type Catalog struct {
-	Header textproto.MIMEHeader
-	msgs   map[string]*Message
-	keys   []string
+	Header   textproto.MIMEHeader
+	Strict   bool // fail on the first invalid message when reading files
+	mu       sync.RWMutex
+	msgs     map[string]*Message
+	keys     []string
+	entries  map[msgKey]*entry // translations used by lookups
+	obsolete []*Message        // kept to be written back to PO files
+	plural   *PluralRule       // rule compiled from the Plural-Forms header
+}
+
+// msgKey identifies a message for lookups. Unlike the keys of msgs, it is
+// built without concatenating the context and the msgid.
+type msgKey struct {
+	ctxt    string
+	hasCtxt bool
+	id      string
+}
+
+// entry holds the translations and flags of a message as strings,
+// converted once when the message is added so that lookups don't allocate.
+type entry struct {
+	str       string
+	strPlural []string
+	flags     []string
+	fuzzy     bool
 }
 
 // Singular returns a singular string stored in the catalog, optionally
 // formatting it using the provided arguments.
+//
+// If the message is not translated, key is used instead.
 func (c *Catalog) Singular(key string, args ...interface{}) string {
-	if msg, ok := c.msgs[key]; ok {
-		if text := msg.Str; text != nil {
-			if len(args) == 0 {
-				return string(text)
-			}
-			return fmt.Sprintf(string(text), args...)
+	return format(c.singular(msgKey{id: key}, key), args)
+}
+
+// ContextSingular is like Singular, but for a message with the given
+// context (msgctxt).
+func (c *Catalog) ContextSingular(ctxt, key string, args ...interface{}) string {
+	return format(c.singular(msgKey{ctxt, true, key}, key), args)
+}
+
+// Plural returns the plural form for n of a string stored in the catalog,
+// optionally formatting it using the provided arguments.
+//
+// The plural form is selected using the Plural-Forms catalog header. If the
+// message is not translated, key is used when n is 1 and keyPlural
+// otherwise, like GNU gettext does.
+func (c *Catalog) Plural(key, keyPlural string, n int, args ...interface{}) string {
+	return format(c.pluralForm(msgKey{id: key}, key, keyPlural, n), args)
+}
+
+// ContextPlural is like Plural, but for a message with the given
+// context (msgctxt).
+func (c *Catalog) ContextPlural(ctxt, key, keyPlural string, n int, args ...interface{}) string {
+	return format(c.pluralForm(msgKey{ctxt, true, key}, key, keyPlural, n), args)
+}
+
+// Lookup returns the translation of a singular message as stored in the
+// catalog, without formatting it, and whether the message is translated.
+// It never allocates.
+func (c *Catalog) Lookup(key string) (string, bool) {
+	return c.lookupSingular(msgKey{id: key})
+}
+
+// ContextLookup is like Lookup, but for a message with the given context
+// (msgctxt).
+func (c *Catalog) ContextLookup(ctxt, key string) (string, bool) {
+	return c.lookupSingular(msgKey{ctxt, true, key})
+}
+
+// PluralLookup returns the plural form for n of a message as stored in the
+// catalog, without formatting it, and whether the form is translated. It
+// never allocates.
+func (c *Catalog) PluralLookup(key string, n int) (string, bool) {
+	return c.lookupPlural(msgKey{id: key}, n)
+}
+
+// ContextPluralLookup is like PluralLookup, but for a message with the
+// given context (msgctxt).
+func (c *Catalog) ContextPluralLookup(ctxt, key string, n int) (string, bool) {
+	return c.lookupPlural(msgKey{ctxt, true, key}, n)
+}
+
+// PluralRule returns the rule used to select plural forms, compiled from
+// the Plural-Forms catalog header. If the header is missing or invalid,
+// DefaultPluralRule is returned.
+func (c *Catalog) PluralRule() *PluralRule {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	forms := c.Header.Get("Plural-Forms")
+	if c.plural != nil && c.plural.String() == forms {
+		return c.plural
+	}
+	if forms == "" {
+		return DefaultPluralRule
+	}
+	// The header was changed after it was read.
+	rule, err := ParsePluralForms(forms)
+	if err != nil {
+		return DefaultPluralRule
+	}
+	return rule
+}
+
+// singular returns the translation stored with the given key, or fallback.
+func (c *Catalog) singular(key msgKey, fallback string) string {
+	if text, ok := c.lookupSingular(key); ok {
+		return text
+	}
+	return fallback
+}
+
+// pluralForm returns the plural translation for n stored with the given
+// key, or one of the fallbacks.
+func (c *Catalog) pluralForm(key msgKey, fallback, fallbackPlural string, n int) string {
+	if text, ok := c.lookupPlural(key, n); ok {
+		return text
+	}
+	if n == 1 {
+		return fallback
+	}
+	return fallbackPlural
+}
+
+// lookupSingular returns the translation stored with the given key, and
+// whether it is translated. Fuzzy messages are not translated.
+func (c *Catalog) lookupSingular(key msgKey) (string, bool) {
+	if e, ok := c.entry(key); ok && !e.fuzzy && e.str != "" {
+		return e.str, true
+	}
+	return "", false
+}
+
+// lookupPlural returns the plural translation for n stored with the given
+// key, and whether it is translated. Fuzzy messages are not translated.
+func (c *Catalog) lookupPlural(key msgKey, n int) (string, bool) {
+	if e, ok := c.entry(key); ok && !e.fuzzy {
+		if idx := c.PluralRule().Index(n); idx < len(e.strPlural) && e.strPlural[idx] != "" {
+			return e.strPlural[idx], true
+		}
+	}
+	return "", false
+}
+
+// hasFlag reports whether the message stored with the given key has the
+// given flag. Fuzzy messages have no flags for lookups, since they are not
+// used.
+func (c *Catalog) hasFlag(key msgKey, plural bool, n int, flag string) bool {
+	e, ok := c.entry(key)
+	if !ok || e.fuzzy {
+		return false
+	}
+	for _, f := range e.flags {
+		if f == flag {
+			return true
 		}
 	}
-	return key
+	return false
+}
+
+// entry returns the lookup entry stored with the given key. Entries are
+// never modified once added, so they can be used without holding c.mu.
+func (c *Catalog) entry(key msgKey) (*entry, bool) {
+	c.mu.RLock()
+	e, ok := c.entries[key]
+	c.mu.RUnlock()
+	return e, ok
 }
 
 // ReadMo reads a MO file from r and adds its messages to the catalog.
+//
+// Messages are converted to UTF-8 from the charset declared in the file
+// header, as done by Decode.
 func (c *Catalog) ReadMo(r io.ReadSeeker) error {
-	iter := ReadMo(r)
-	size := iter.Size()
-	for i := 0; i < size; i++ {
+	return c.read(Decode(ReadMo(r)))
+}
+
+// ReadPo reads a PO file from r and adds its messages to the catalog.
+//
+// Messages are converted to UTF-8 from the charset declared in the file
+// header, as done by Decode. Obsolete messages are not used for lookups,
+// but they are kept to be returned by Iter().
+func (c *Catalog) ReadPo(r io.Reader) error {
+	return c.read(Decode(ReadPo(r)))
+}
+
+// read adds the messages provided by iter to the catalog.
+//
+// Errors from the iterator are returned as is. Invalid messages are
+// returned in a MultiError, unless the catalog is strict.
+func (c *Catalog) read(iter Iterator) error {
+	var errs MultiError
+	for {
 		msg, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
 			return err
 		}
-		// TODO: check this error
-		c.setMessage(msg)
+		if err := c.setMessage(msg); err != nil {
+			if c.Strict {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return errs
 	}
 	return nil
 }
 
 func (c *Catalog) setMessage(msg *Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if msg.Meta != nil && msg.Meta.Obsolete {
+		c.obsolete = append(c.obsolete, msg)
+		return nil
+	}
 	key, err := c.key(msg.Ctxt, msg.Id)
 	if err != nil {
 		return err
@@ -75,9 +266,38 @@ func (c *Catalog) setMessage(msg *Message) error {
 	}
 	c.msgs[key] = msg
 	c.keys = append(c.keys, key)
+	c.entries[msgKey{string(msg.Ctxt), msg.Ctxt != nil, string(msg.Id)}] = newEntry(msg)
+	if forms := c.Header.Get("Plural-Forms"); len(key) == 0 && forms != "" {
+		rule, err := ParsePluralForms(forms)
+		if err != nil {
+			return err
+		}
+		c.plural = rule
+	}
 	return nil
 }
 
+// newEntry returns the lookup entry of a message. Changes made later to the
+// message are not seen by lookups.
+func newEntry(msg *Message) *entry {
+	e := &entry{
+		str:   string(msg.Str),
+		fuzzy: msg.HasFlag("fuzzy"),
+	}
+	if msg.Meta != nil {
+		for _, flag := range msg.Meta.Flags {
+			e.flags = append(e.flags, string(flag))
+		}
+	}
+	if msg.StrPlural != nil {
+		e.strPlural = make([]string, len(msg.StrPlural))
+		for i, str := range msg.StrPlural {
+			e.strPlural[i] = string(str)
+		}
+	}
+	return e
+}
+
 func (c *Catalog) key(ctxt, id []byte) (string, error) {
 	if id == nil {
 		return "", fmt.Errorf("Invalid msgid.")
@@ -88,38 +308,40 @@ func (c *Catalog) key(ctxt, id []byte) (string, error) {
 	return fmt.Sprintf("%s%s%s", ctxt, string('\x04'), id), nil
 }
 
-// Iter returns a messages iterator for this catalog.
-func (c *Catalog) Iter() Iterator {
-	// Note: as it is, new messages can't be added to the catalog when using
-	// the iterator, because it would result in unsorted keys.
-	sort.Strings(c.keys)
-	return &catalogIterator{ctg: c}
+// contextKey returns the catalog key for a message with context.
+func contextKey(ctxt, id string) string {
+	return ctxt + "\x04" + id
 }
 
-// ----------------------------------------------------------------------------
-
-// catalogIterator iterates over the messages stored in a catalog.
-type catalogIterator struct {
-	ctg *Catalog
-	pos int
-}
-
-// Size returns the amount of messages provided by the iterator.
-func (i *catalogIterator) Size() int {
-	return len(i.ctg.keys)
+// format formats s using the provided arguments, if any.
+func format(s string, args []interface{}) string {
+	if len(args) == 0 {
+		return s
+	}
+	return fmt.Sprintf(s, args...)
 }
 
-// Next returns the next message. At the end of the iteration,
-// io.EOF is returned as the error.
-func (i *catalogIterator) Next() (*Message, error) {
-	if i.pos < len(i.ctg.keys) {
-		key := i.ctg.keys[i.pos]
-		msg := i.ctg.msgs[key]
+// Iter returns a messages iterator for this catalog.
+//
+// Messages are sorted by key, followed by obsolete messages, if any. The
+// iterator provides the messages in the catalog when Iter is called, so
+// messages can be added to the catalog while iterating.
+func (c *Catalog) Iter() Iterator {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys := append([]string(nil), c.keys...)
+	sort.Strings(keys)
+	msgs := make([]*Message, 0, len(keys)+len(c.obsolete))
+	for _, key := range keys {
+		msg := c.msgs[key]
 		if len(key) == 0 {
-			msg.Str = headerToBytes(i.ctg.Header)
+			// Copy the header message instead of modifying the stored one.
+			header := *msg
+			header.Str = headerToBytes(c.Header)
+			msg = &header
 		}
-		i.pos += 1
-		return msg, nil
+		msgs = append(msgs, msg)
 	}
-	return nil, io.EOF
+	msgs = append(msgs, c.obsolete...)
+	return &messageIterator{msgs: msgs}
 }
@@ -0,0 +1,236 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var polishPoData = `msgid ""
+msgstr ""
+"Language: pl\n"
+"Plural-Forms: nplurals=3; plural=(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);\n"
+
+msgid "Open"
+msgstr "Otwórz"
+
+msgctxt "door"
+msgid "Open"
+msgstr "Otwarte"
+
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d plik"
+msgstr[1] "%d pliki"
+msgstr[2] "%d plików"
+
+msgctxt "disk"
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d plik na dysku"
+msgstr[1] "%d pliki na dysku"
+msgstr[2] "%d plików na dysku"
+
+#, fuzzy
+msgid "Close"
+msgstr "Zamknij"
+
+msgid "Save"
+msgstr ""
+`
+
+func TestCatalogLookup(t *testing.T) {
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(polishPoData)); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		got, expected string
+	}{
+		{c.Singular("Open"), "Otwórz"},
+		{c.ContextSingular("door", "Open"), "Otwarte"},
+		{c.ContextSingular("window", "Open"), "Open"},
+		{c.Singular("Close"), "Close"},
+		{c.Singular("Save"), "Save"},
+		{c.Singular("Missing %d", 3), "Missing 3"},
+		{c.Plural("%d file", "%d files", 1, 1), "1 plik"},
+		{c.Plural("%d file", "%d files", 3, 3), "3 pliki"},
+		{c.Plural("%d file", "%d files", 5, 5), "5 plików"},
+		{c.Plural("%d file", "%d files", 22, 22), "22 pliki"},
+		{c.ContextPlural("disk", "%d file", "%d files", 12, 12), "12 plików na dysku"},
+		{c.ContextPlural("tape", "%d file", "%d files", 1, 1), "1 file"},
+		{c.Plural("%d dir", "%d dirs", 1, 1), "1 dir"},
+		{c.Plural("%d dir", "%d dirs", 2, 2), "2 dirs"},
+	}
+	for i, test := range tests {
+		if test.got != test.expected {
+			t.Errorf("%d: expected %q, got %q.", i, test.expected, test.got)
+		}
+	}
+	if n := c.PluralRule().NPlurals; n != 3 {
+		t.Errorf("Expected 3 plural forms, got %d.", n)
+	}
+
+	// Lookups without formatting. An empty ctxt means no context, and a
+	// negative n a singular lookup.
+	lookups := []struct {
+		key, ctxt string
+		n         int
+		expected  string
+	}{
+		{"Open", "", -1, "Otwórz"},
+		{"Open", "door", -1, "Otwarte"},
+		{"Open", "window", -1, ""},
+		{"Save", "", -1, ""},
+		{"%d file", "", 3, "%d pliki"},
+		{"%d file", "disk", 12, "%d plików na dysku"},
+		{"%d dir", "", 2, ""},
+	}
+	for i, test := range lookups {
+		var got string
+		var ok bool
+		switch {
+		case test.n < 0 && test.ctxt == "":
+			got, ok = c.Lookup(test.key)
+		case test.n < 0:
+			got, ok = c.ContextLookup(test.ctxt, test.key)
+		case test.ctxt == "":
+			got, ok = c.PluralLookup(test.key, test.n)
+		default:
+			got, ok = c.ContextPluralLookup(test.ctxt, test.key, test.n)
+		}
+		if got != test.expected || ok != (test.expected != "") {
+			t.Errorf("%d: expected %q, got %q (%v).", i, test.expected, got, ok)
+		}
+	}
+
+	// Lookups use the messages as they were added.
+	iter := c.Iter()
+	for msg, err := iter.Next(); err == nil; msg, err = iter.Next() {
+		if string(msg.Id) == "Open" && msg.Ctxt == nil {
+			msg.Str = []byte("Otwieraj")
+			msg.Meta = &MessageMeta{Flags: [][]byte{[]byte("fuzzy"), []byte("c-format")}}
+		}
+	}
+	if s := c.Singular("Open"); s != "Otwórz" {
+		t.Errorf("Expected %q, got %q.", "Otwórz", s)
+	}
+	if c.hasFlag(msgKey{id: "Open"}, false, 0, "c-format") {
+		t.Errorf("Expected no c-format flag.")
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		c.Singular("Open")
+		c.ContextSingular("door", "Open")
+		c.Plural("%d file", "%d files", 5)
+		c.ContextPlural("disk", "%d file", "%d files", 12)
+		c.Lookup("Open")
+		c.ContextPluralLookup("disk", "%d file", 12)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v.", allocs)
+	}
+}
+
+func TestCatalogConcurrency(t *testing.T) {
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(polishPoData)); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Singular("Open")
+				c.ContextPlural("disk", "%d file", "%d files", j)
+				c.PluralRule()
+				if err := WriteMo(ioutil.Discard, c.Iter()); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	for i := 0; i < 100; i++ {
+		po := fmt.Sprintf("msgid \"Message %d\"\nmsgstr \"Wiadomość %d\"\n", i, i)
+		if err := c.ReadPo(strings.NewReader(po)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	if got := c.Singular("Message 42"); got != "Wiadomość 42" {
+		t.Errorf("Expected %q, got %q.", "Wiadomość 42", got)
+	}
+}
+
+func BenchmarkSingular(b *testing.B) {
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(polishPoData)); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.Singular("Open")
+	}
+}
+
+func BenchmarkContextPlural(b *testing.B) {
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(polishPoData)); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.ContextPlural("disk", "%d file", "%d files", 12)
+	}
+}
+
+var invalidPoData = `msgid ""
+msgstr ""
+"Plural-Forms: nplurals=2; plural=n > 1 ? 2 : 0;\n"
+
+msgid "Open"
+msgstr "Abrir"
+
+msgid "Open"
+msgstr "Abierto"
+
+msgid ""
+msgstr "Language: es\n"
+
+msgid "Close"
+msgstr "Cerrar"
+`
+
+func TestCatalogReadErrors(t *testing.T) {
+	c := NewCatalog()
+	err := c.ReadPo(strings.NewReader(invalidPoData))
+	errs, ok := err.(MultiError)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("Expected 3 errors, got %v.", err)
+	}
+	if s := c.Singular("Close"); s != "Cerrar" {
+		t.Errorf("Expected valid messages to be kept, got %q.", s)
+	}
+	if s := c.Singular("Open"); s != "Abrir" {
+		t.Errorf("Expected the first message to be kept, got %q.", s)
+	}
+
+	c = NewCatalog()
+	c.Strict = true
+	err = c.ReadPo(strings.NewReader(invalidPoData))
+	if _, ok := err.(MultiError); ok || err == nil {
+		t.Fatalf("Expected a single error, got %v.", err)
+	}
+	if s := c.Singular("Close"); s != "Close" {
+		t.Errorf("Expected reading to stop at the first error, got %q.", s)
+	}
+}
@@ -0,0 +1,352 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Decode returns an iterator that converts the messages provided by iter
+// to UTF-8, from the charset declared in the Content-Type field of the
+// catalog header. The charset of the header is changed to UTF-8.
+//
+// The header must be the first message, as in files written by GNU gettext
+// and this package. If there is no header, or it declares no charset,
+// messages are assumed to be UTF-8 already.
+//
+// Supported charsets are UTF-8, US-ASCII, ISO-8859-1 to ISO-8859-16,
+// KOI8-R, KOI8-U and Windows-1250 to Windows-1258, also known as CP1250
+// to CP1258. Bytes undefined in the charset are converted to U+FFFD.
+// Messages in other charsets, such as EUC-JP or Big5, are provided as they
+// are, and the header keeps its charset.
+func Decode(iter Iterator) Iterator {
+	return &charsetIterator{iter: iter}
+}
+
+// Encode returns an iterator that converts the messages provided by iter
+// from UTF-8 to the given charset, and declares it in the Content-Type
+// field of the catalog header. Supported charsets are the same as for
+// Decode.
+//
+// Messages that can't be represented in the charset result in an error.
+func Encode(iter Iterator, charset string) Iterator {
+	return &charsetIterator{iter: iter, target: charset, encode: true}
+}
+
+// ----------------------------------------------------------------------------
+
+// charsetIterator converts the messages of another iterator from or to
+// UTF-8.
+type charsetIterator struct {
+	iter    Iterator
+	target  string        // charset to encode to
+	encode  bool          // whether to encode or decode
+	conv    *charsetTable // charset to convert from or to, nil for UTF-8
+	started bool          // whether the first message was read
+	raw     bool          // whether the charset is unsupported for decoding
+	err     error         // iterator error
+}
+
+// Size returns the amount of messages provided by the iterator.
+func (i *charsetIterator) Size() int {
+	return i.iter.Size()
+}
+
+// Next returns the next message. At the end of the iteration,
+// io.EOF is returned as the error.
+func (i *charsetIterator) Next() (*Message, error) {
+	if i.err != nil {
+		return nil, i.err
+	}
+	msg, err := i.next()
+	if err != nil {
+		i.err = err
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (i *charsetIterator) next() (*Message, error) {
+	msg, err := i.iter.Next()
+	if err != nil {
+		return nil, err
+	}
+	isHeader := false
+	if !i.started {
+		i.started = true
+		if isHeader = msg.Ctxt == nil && len(msg.Id) == 0; isHeader {
+			charset := CatalogHeader(bytesToHeader(msg.Str)).Charset()
+			if i.encode {
+				charset = i.target
+			}
+			if i.conv, err = lookupCharset(charset); err != nil {
+				if i.encode {
+					return nil, err
+				}
+				i.raw = true
+			}
+		} else if i.encode {
+			if i.conv, err = lookupCharset(i.target); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if i.conv != nil {
+		convert := i.conv.decode
+		if i.encode {
+			convert = i.conv.encode
+		}
+		if msg, err = convertMessage(msg, convert); err != nil {
+			return nil, err
+		}
+	}
+	if isHeader && !i.raw {
+		charset := "UTF-8"
+		if i.encode {
+			charset = i.target
+		}
+		msg.Str = setHeaderCharset(msg.Str, charset)
+	}
+	return msg, nil
+}
+
+// convertMessage returns a copy of msg with all its strings converted.
+func convertMessage(msg *Message, convert func([]byte) ([]byte, error)) (*Message, error) {
+	var err error
+	str := func(b []byte) []byte {
+		if err == nil && b != nil {
+			b, err = convert(b)
+		}
+		return b
+	}
+	list := func(l [][]byte) [][]byte {
+		if l == nil {
+			return nil
+		}
+		c := make([][]byte, len(l))
+		for k, b := range l {
+			c[k] = str(b)
+		}
+		return c
+	}
+	m := &Message{
+		Ctxt:      str(msg.Ctxt),
+		Id:        str(msg.Id),
+		IdPlural:  str(msg.IdPlural),
+		Str:       str(msg.Str),
+		StrPlural: list(msg.StrPlural),
+	}
+	if meta := msg.Meta; meta != nil {
+		m.Meta = &MessageMeta{
+			TranslatorComments: list(meta.TranslatorComments),
+			ExtractedComments:  list(meta.ExtractedComments),
+			References:         list(meta.References),
+			Flags:              list(meta.Flags),
+			PrevCtxt:           str(meta.PrevCtxt),
+			PrevId:             str(meta.PrevId),
+			PrevIdPlural:       str(meta.PrevIdPlural),
+			Obsolete:           meta.Obsolete,
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// setHeaderCharset replaces the charset declared in the Content-Type field
+// of a catalog header, keeping the other fields as they are. The field is
+// added if it is missing.
+func setHeaderCharset(header []byte, charset string) []byte {
+	lines := bytes.SplitAfter(header, []byte("\n"))
+	for k, line := range lines {
+		idx := bytes.IndexByte(line, ':')
+		if idx == -1 || !strings.EqualFold(string(line[:idx]), "Content-Type") {
+			continue
+		}
+		h := CatalogHeader{}
+		h.Set("Content-Type", string(bytes.TrimSpace(line[idx+1:])))
+		if h.Charset() == charset {
+			return header
+		}
+		h.SetCharset(charset)
+		c := make([]byte, 0, len(header)+len(charset))
+		for _, l := range lines[:k] {
+			c = append(c, l...)
+		}
+		c = append(c, "Content-Type: "+h.Get("Content-Type")+"\n"...)
+		for _, l := range lines[k+1:] {
+			c = append(c, l...)
+		}
+		return c
+	}
+	c := append([]byte{}, header...)
+	if len(c) > 0 && c[len(c)-1] != '\n' {
+		c = append(c, '\n')
+	}
+	return append(c, "Content-Type: text/plain; charset="+charset+"\n"...)
+}
+
+// ----------------------------------------------------------------------------
+
+// charsetAliases maps alternative names of charsets to the keys of
+// charsetTables.
+var charsetAliases = map[string]string{
+	"latin1":   "iso88591",
+	"latin2":   "iso88592",
+	"latin3":   "iso88593",
+	"latin4":   "iso88594",
+	"cyrillic": "iso88595",
+	"arabic":   "iso88596",
+	"greek":    "iso88597",
+	"hebrew":   "iso88598",
+	"latin5":   "iso88599",
+	"latin6":   "iso885910",
+	"latin7":   "iso885913",
+	"latin8":   "iso885914",
+	"latin9":   "iso885915",
+	"latin10":  "iso885916",
+	"cp1250":   "windows1250",
+	"cp1251":   "windows1251",
+	"cp1252":   "windows1252",
+	"cp1253":   "windows1253",
+	"cp1254":   "windows1254",
+	"cp1255":   "windows1255",
+	"cp1256":   "windows1256",
+	"cp1257":   "windows1257",
+	"cp1258":   "windows1258",
+}
+
+// asciiTable maps the upper half of US-ASCII, where no byte is defined.
+var asciiTable [128]rune
+
+func init() {
+	for k := range asciiTable {
+		asciiTable[k] = utf8.RuneError
+	}
+}
+
+var (
+	charsetMutex sync.Mutex
+	charsetCache = map[string]*charsetTable{}
+)
+
+// lookupCharset returns the conversion table for a charset, or nil for
+// UTF-8. POT files declare the "CHARSET" placeholder, which is handled as
+// UTF-8 too.
+func lookupCharset(name string) (*charsetTable, error) {
+	key := normalizeCharset(name)
+	if alias, ok := charsetAliases[key]; ok {
+		key = alias
+	}
+	var high *[128]rune
+	switch key {
+	case "", "utf8", "charset":
+		return nil, nil
+	case "ascii", "usascii", "ansix341968":
+		key, high = "ascii", &asciiTable
+	default:
+		if high = charsetTables[key]; high == nil {
+			return nil, fmt.Errorf("Unsupported charset %q.", name)
+		}
+	}
+	charsetMutex.Lock()
+	defer charsetMutex.Unlock()
+	t := charsetCache[key]
+	if t == nil {
+		t = newCharsetTable(name, high)
+		charsetCache[key] = t
+	}
+	return t, nil
+}
+
+// normalizeCharset returns the charset name in lower case, without
+// punctuation, so that "ISO-8859-1" and "iso_8859_1" are the same.
+func normalizeCharset(name string) string {
+	b := make([]byte, 0, len(name))
+	for k := 0; k < len(name); k++ {
+		c := name[k]
+		switch {
+		case 'A' <= c && c <= 'Z':
+			b = append(b, c+'a'-'A')
+		case 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
+
+// charsetTable converts between UTF-8 and a single-byte charset that is
+// compatible with ASCII.
+type charsetTable struct {
+	name  string        // charset name, for errors
+	high  *[128]rune    // runes for bytes 0x80 to 0xff
+	runes map[rune]byte // reverse of high
+}
+
+func newCharsetTable(name string, high *[128]rune) *charsetTable {
+	t := &charsetTable{name: name, high: high, runes: map[rune]byte{}}
+	for k, r := range high {
+		if r != utf8.RuneError {
+			t.runes[r] = byte(0x80 + k)
+		}
+	}
+	return t
+}
+
+// decode converts b from the charset to UTF-8. ASCII strings are returned
+// without copying.
+func (t *charsetTable) decode(b []byte) ([]byte, error) {
+	if isASCII(b) {
+		return b, nil
+	}
+	d := make([]byte, 0, len(b)*2)
+	var buf [utf8.UTFMax]byte
+	for _, c := range b {
+		if c < utf8.RuneSelf {
+			d = append(d, c)
+		} else {
+			n := utf8.EncodeRune(buf[:], t.high[c-0x80])
+			d = append(d, buf[:n]...)
+		}
+	}
+	return d, nil
+}
+
+// encode converts b from UTF-8 to the charset. ASCII strings are returned
+// without copying.
+func (t *charsetTable) encode(b []byte) ([]byte, error) {
+	if isASCII(b) {
+		return b, nil
+	}
+	e := make([]byte, 0, len(b))
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		switch c, ok := t.runes[r]; {
+		case r < utf8.RuneSelf:
+			e = append(e, byte(r))
+		case ok:
+			e = append(e, c)
+		default:
+			return nil, fmt.Errorf("Unable to encode %q in charset %s.", b[:size], t.name)
+		}
+		b = b[size:]
+	}
+	return e, nil
+}
+
+// isASCII reports whether b only contains ASCII characters.
+func isASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
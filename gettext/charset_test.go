@@ -0,0 +1,187 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"bytes"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// Russian catalog; the translations are encoded in KOI8-R below.
+var koi8PoData = `msgid ""
+msgstr ""
+"Language: ru\n"
+"Content-Type: text/plain; charset=KOI8-R\n"
+
+# Комментарий
+msgid "Open"
+msgstr "Открыть"
+
+msgid "Закрыть"
+msgstr "Close"
+`
+
+func TestDecode(t *testing.T) {
+	data, err := Encode(ReadPo(strings.NewReader(koi8PoData)), "KOI8-R").Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data.Str), "charset=KOI8-R") {
+		t.Errorf("Unexpected header %q.", data.Str)
+	}
+	// Write the catalog in KOI8-R and read it back.
+	b := new(bytes.Buffer)
+	if err := WritePo(b, Encode(ReadPo(strings.NewReader(koi8PoData)), "KOI8-R")); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b.Bytes(), []byte("\xef\xd4\xcb\xd2\xd9\xd4\xd8")) {
+		t.Errorf("Expected translation in KOI8-R, got %q.", b.Bytes())
+	}
+	iter := Decode(ReadPo(b))
+	header, err := iter.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := CatalogHeader(bytesToHeader(header.Str)); h.Charset() != "UTF-8" || h.Language() != "ru" {
+		t.Errorf("Unexpected header %q.", header.Str)
+	}
+	msg, err := iter.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Str) != "Открыть" || string(msg.Meta.TranslatorComments[0]) != "Комментарий" {
+		t.Errorf("Unexpected message %q, %q.", msg.Str, msg.Meta.TranslatorComments)
+	}
+}
+
+func TestCatalogReadCharset(t *testing.T) {
+	po := "msgid \"\"\nmsgstr \"Content-Type: text/plain; charset=ISO-8859-1\\n\"\n\n" +
+		"msgid \"Caf\xe9\"\nmsgstr \"Caf\xe9 cr\xe8me\"\n"
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(po)); err != nil {
+		t.Fatal(err)
+	}
+	if s := c.Singular("Café"); s != "Café crème" {
+		t.Errorf("Expected %q, got %q.", "Café crème", s)
+	}
+	if charset := CatalogHeader(c.Header).Charset(); charset != "UTF-8" {
+		t.Errorf("Expected UTF-8 charset, got %q.", charset)
+	}
+
+	b := new(bytes.Buffer)
+	if err := WriteMo(b, Encode(ReadPo(strings.NewReader(koi8PoData)), "CP1251")); err != nil {
+		t.Fatal(err)
+	}
+	c = NewCatalog()
+	if err := c.ReadMo(bytes.NewReader(b.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	mc, err := OpenMoBytes(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ctg := range []interface {
+		Singular(string, ...interface{}) string
+	}{c, mc} {
+		if s := ctg.Singular("Open"); s != "Открыть" {
+			t.Errorf("Expected %q, got %q.", "Открыть", s)
+		}
+		if s := ctg.Singular("Закрыть"); s != "Close" {
+			t.Errorf("Expected %q, got %q.", "Close", s)
+		}
+	}
+	if charset := CatalogHeader(mc.Header).Charset(); charset != "UTF-8" {
+		t.Errorf("Expected UTF-8 charset, got %q.", charset)
+	}
+}
+
+// Japanese catalog in EUC-JP, which can't be decoded.
+var eucJPPoData = "msgid \"\"\nmsgstr \"\"\n" +
+	"\"Language: ja\\n\"\n" +
+	"\"Content-Type: text/plain; charset=EUC-JP\\n\"\n\n" +
+	"msgid \"Open\"\nmsgstr \"\xb3\xab\xa4\xaf\"\n"
+
+func TestCharsetUnsupported(t *testing.T) {
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(eucJPPoData)); err != nil {
+		t.Fatal(err)
+	}
+	b := new(bytes.Buffer)
+	if err := WriteMo(b, ReadPo(strings.NewReader(eucJPPoData))); err != nil {
+		t.Fatal(err)
+	}
+	mc, err := OpenMoBytes(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Strings are kept as they are, with the charset of the file.
+	for _, ctg := range []struct {
+		c interface {
+			Singular(string, ...interface{}) string
+		}
+		header textproto.MIMEHeader
+	}{{c, c.Header}, {mc, mc.Header}} {
+		if s := ctg.c.Singular("Open"); s != "\xb3\xab\xa4\xaf" {
+			t.Errorf("Expected %q, got %q.", "\xb3\xab\xa4\xaf", s)
+		}
+		if charset := CatalogHeader(ctg.header).Charset(); charset != "EUC-JP" {
+			t.Errorf("Expected %q, got %q.", "EUC-JP", charset)
+		}
+	}
+}
+
+func TestCharsetErrors(t *testing.T) {
+	b := new(bytes.Buffer)
+	if err := WritePo(b, Encode(ReadPo(strings.NewReader(koi8PoData)), "EUC-JP")); err == nil {
+		t.Errorf("Expected unsupported charset error.")
+	}
+	if err := WritePo(b, Encode(ReadPo(strings.NewReader(koi8PoData)), "ISO-8859-1")); err == nil {
+		t.Errorf("Expected encoding error.")
+	}
+}
+
+func TestCharsetTables(t *testing.T) {
+	tests := []struct {
+		charset string
+		b       byte
+		r       rune
+	}{
+		{"Windows-1252", 0x80, '€'},
+		{"cp1252", 0x81, '�'},
+		{"KOI8-R", 0xc1, 'а'},
+		{"koi8-u", 0xa4, 'є'},
+		{"ISO-8859-2", 0xb1, 'ą'},
+		{"iso_8859-15", 0xa4, '€'},
+		{"latin1", 0xe9, 'é'},
+		{"US-ASCII", 0xe9, '�'},
+	}
+	for _, test := range tests {
+		conv, err := lookupCharset(test.charset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, _ := conv.decode([]byte{'a', test.b})
+		if s := string(d); s != "a"+string(test.r) {
+			t.Errorf("%s: expected %q, got %q.", test.charset, "a"+string(test.r), s)
+		}
+	}
+	// Every defined byte must survive a round trip.
+	for name := range charsetTables {
+		conv, _ := lookupCharset(name)
+		for c := 0x80; c < 0x100; c++ {
+			d, _ := conv.decode([]byte{byte(c)})
+			if e, err := conv.encode(d); err == nil && (len(e) != 1 || e[0] != byte(c)) {
+				t.Errorf("%s: %#x was encoded back as %q.", name, c, e)
+			} else if err != nil && string(d) != "�" {
+				t.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+	if conv, err := lookupCharset("CHARSET"); conv != nil || err != nil {
+		t.Errorf("Expected the POT placeholder to be handled as UTF-8.")
+	}
+}
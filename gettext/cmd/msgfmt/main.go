@@ -0,0 +1,199 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Command msgfmt compiles gettext PO files into MO files, like GNU msgfmt.
+
+Usage:
+
+	msgfmt [flags] file.po...
+
+Each input is compiled into a MO file with the same name and the .mo
+extension, unless -o is given for a single input. Untranslated, fuzzy and
+obsolete messages are not included.
+
+The flags are:
+
+	-o file
+		output file, for a single input; "-" for the standard output
+	-check-format
+		check that translations use the same formatting directives as
+		the msgids, for messages flagged with go-format or c-format
+	-statistics
+		print the amount of translated, fuzzy and untranslated messages
+	-use-fuzzy
+		include fuzzy messages
+	-no-hash
+		don't include a hash table in the MO files
+
+Flags can also be given with two dashes, as in --statistics.
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/i18n/gettext"
+)
+
+var (
+	output      = flag.String("o", "", "output `file`, for a single input; \"-\" for the standard output")
+	checkFormat = flag.Bool("check-format", false, "check formatting directives of go-format and c-format messages")
+	statistics  = flag.Bool("statistics", false, "print statistics about the translations")
+	useFuzzy    = flag.Bool("use-fuzzy", false, "include fuzzy messages")
+	noHash      = flag.Bool("no-hash", false, "don't include a hash table")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: msgfmt [flags] file.po...\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || *output != "" && flag.NArg() > 1 {
+		flag.Usage()
+	}
+	failed := false
+	for _, input := range flag.Args() {
+		err := compile(input)
+		if errs, ok := err.(gettext.MultiError); ok {
+			for _, err := range errs {
+				fmt.Fprintf(os.Stderr, "msgfmt: %s: %v\n", input, err)
+			}
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "msgfmt: %s: %v\n", input, err)
+		}
+		failed = failed || err != nil
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// compile compiles a PO file.
+func compile(input string) error {
+	f, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	iter := gettext.ReadPo(f)
+	var msgs []*gettext.Message
+	var translated, fuzzy, untranslated int
+	var errs gettext.MultiError
+	seen := map[string]bool{}
+	for {
+		msg, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Meta != nil && msg.Meta.Obsolete {
+			continue
+		}
+		key := string(msg.Id)
+		if msg.Ctxt != nil {
+			key = string(msg.Ctxt) + "\x04" + key
+		}
+		if seen[key] {
+			errs = append(errs, fmt.Errorf("Duplicate message %q.", key))
+			continue
+		}
+		seen[key] = true
+		switch {
+		case key == "":
+			// The header is always included.
+		case !isTranslated(msg):
+			untranslated++
+			continue
+		case msg.HasFlag("fuzzy"):
+			fuzzy++
+			if !*useFuzzy {
+				continue
+			}
+		default:
+			translated++
+		}
+		if *checkFormat {
+			if err := gettext.CheckFormat(msg); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		msgs = append(msgs, msg)
+	}
+	if *statistics {
+		fmt.Fprintf(os.Stderr, "%s: %d translated messages, %d fuzzy translations, %d untranslated messages.\n",
+			input, translated, fuzzy, untranslated)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	b := new(bytes.Buffer)
+	if err := gettext.WriteMoOptions(b, &messages{msgs: msgs}, &gettext.MoOptions{NoHashTable: *noHash}); err != nil {
+		return err
+	}
+	switch name := *output; name {
+	case "-":
+		_, err = os.Stdout.Write(b.Bytes())
+		return err
+	case "":
+		name = strings.TrimSuffix(input, filepath.Ext(input)) + ".mo"
+		fallthrough
+	default:
+		return writeFile(name, b.Bytes())
+	}
+}
+
+// writeFile writes data to the named file.
+func writeFile(name string, data []byte) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// isTranslated reports whether msg has a translation.
+func isTranslated(msg *gettext.Message) bool {
+	if msg.IdPlural == nil {
+		return len(msg.Str) > 0
+	}
+	for _, s := range msg.StrPlural {
+		if len(s) == 0 {
+			return false
+		}
+	}
+	return len(msg.StrPlural) > 0
+}
+
+// messages iterates over a list of messages.
+type messages struct {
+	msgs []*gettext.Message
+	pos  int
+}
+
+func (m *messages) Size() int {
+	return len(m.msgs)
+}
+
+func (m *messages) Next() (*gettext.Message, error) {
+	if m.pos >= len(m.msgs) {
+		return nil, io.EOF
+	}
+	m.pos++
+	return m.msgs[m.pos-1], nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Command msggen compiles a gettext PO or MO file into Go source code, so
+that programs can embed their translations instead of reading MO files at
+startup.
+
+Usage:
+
+	msggen [flags] file.po
+
+Files with the .mo extension are read as MO files. The generated file
+declares a variable whose Singular, ContextSingular, Plural and
+ContextPlural methods look up the translations, as done by gettext.WriteGo.
+For example:
+
+	msggen -package translations -name Polish -o translations/pl.go pl.po
+
+The flags are:
+
+	-o file
+		output file, or "-" for the standard output (default "-")
+	-package name
+		package name of the generated file (default "translations")
+	-name name
+		name of the generated variable (default "Catalog")
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gorilla/i18n/gettext"
+)
+
+var (
+	output      = flag.String("o", "-", "output `file`, or \"-\" for the standard output")
+	packageName = flag.String("package", "translations", "package `name` of the generated file")
+	varName     = flag.String("name", "Catalog", "`name` of the generated variable")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: msggen [flags] file.po\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+	}
+	if err := run(flag.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "msggen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(input string) error {
+	src, err := ioutil.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	var iter gettext.Iterator
+	if filepath.Ext(input) == ".mo" {
+		iter = gettext.ReadMo(bytes.NewReader(src))
+	} else {
+		iter = gettext.ReadPo(bytes.NewReader(src))
+	}
+	b := new(bytes.Buffer)
+	opt := &gettext.GoOptions{Package: *packageName, Name: *varName}
+	if err := gettext.WriteGo(b, iter, opt); err != nil {
+		return fmt.Errorf("%s: %v", input, err)
+	}
+	if *output == "-" {
+		_, err = os.Stdout.Write(b.Bytes())
+		return err
+	}
+	return ioutil.WriteFile(*output, b.Bytes(), 0644)
+}
@@ -0,0 +1,75 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Command msgunfmt decompiles a gettext MO file into a PO file, like GNU
+msgunfmt.
+
+Usage:
+
+	msgunfmt [flags] file.mo
+
+Messages are written sorted by context and msgid, and converted to UTF-8.
+
+The flags are:
+
+	-o file
+		output file, or "-" for the standard output (default "-")
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/gorilla/i18n/gettext"
+)
+
+var output = flag.String("o", "-", "output `file`, or \"-\" for the standard output")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: msgunfmt [flags] file.mo\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+	}
+	if err := run(flag.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "msgunfmt: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(input string) error {
+	c := gettext.NewCatalog()
+	if err := readMo(c, input); err != nil {
+		return fmt.Errorf("%s: %v", input, err)
+	}
+	if *output == "-" {
+		return gettext.WritePo(os.Stdout, c.Iter())
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := gettext.WritePo(f, c.Iter()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readMo reads a MO file into a catalog.
+func readMo(c *gettext.Catalog, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.ReadMo(f)
+}
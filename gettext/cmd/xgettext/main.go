@@ -0,0 +1,216 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Command xgettext extracts translatable strings from Go packages and their
+templates, and writes them to a gettext template (POT file).
+
+Usage:
+
+	xgettext [flags] [packages]
+
+Packages are given as directories, "." by default. A "/..." suffix, as in
+"./...", includes all the packages under the directory. Strings are
+extracted from calls to the lookup methods of gettext.Catalog, and to
+functions given with -k, such as:
+
+	xgettext -k Tr -k TrN:1,2 -o po/messages.pot ./...
+
+Files with the extensions given with -templates are read as text/template
+or html/template files. Strings are extracted from calls to the T, TC, TN
+and TNC template functions, and to functions given with -tk.
+
+The flags are:
+
+	-o file
+		output file, or "-" for the standard output (default "messages.pot")
+	-k spec
+		extract calls to the given function, in the format of GNU
+		xgettext: "Name:1c,2,3" for context, msgid and msgid_plural
+		arguments. Can be repeated
+	-templates exts
+		comma-separated extensions of template files (default ".tmpl")
+	-tk spec
+		like -k, for templates
+	-no-default-keywords
+		only use the keywords given with -k and -tk
+	-add-comments tag
+		extract comments starting with tag for translators
+		(default "TRANSLATORS:")
+	-tests
+		include test files
+	-package-name, -package-version, -msgid-bugs-address
+		values of the template header
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/i18n/gettext"
+	"github.com/gorilla/i18n/gettext/extract"
+)
+
+var (
+	output            = flag.String("o", "messages.pot", "output file, or \"-\" for the standard output")
+	noDefaultKeywords = flag.Bool("no-default-keywords", false, "only use the keywords given with -k and -tk")
+	templateExts      = flag.String("templates", ".tmpl", "comma-separated `extensions` of template files")
+	commentTag        = flag.String("add-comments", "TRANSLATORS:", "extract comments starting with `tag` for translators")
+	tests             = flag.Bool("tests", false, "include test files")
+	packageName       = flag.String("package-name", "PACKAGE", "package name for the header")
+	packageVersion    = flag.String("package-version", "VERSION", "package version for the header")
+	bugsAddress       = flag.String("msgid-bugs-address", "", "address to report bugs in the messages")
+	keywords          keywordList
+	templateKeywords  keywordList
+)
+
+func init() {
+	flag.Var(&keywords, "k", "extract calls to the function given by `spec`, such as \"Name:1c,2,3\"")
+	flag.Var(&templateKeywords, "tk", "like -k, for templates")
+}
+
+// keywordList is a flag.Value that collects keywords.
+type keywordList []extract.Keyword
+
+func (l *keywordList) String() string {
+	var specs []string
+	for _, k := range *l {
+		specs = append(specs, k.String())
+	}
+	return strings.Join(specs, " ")
+}
+
+func (l *keywordList) Set(spec string) error {
+	k, err := extract.ParseKeyword(spec)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, k)
+	return nil
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: xgettext [flags] [packages]\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	flag.Parse()
+	if err := run(flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "xgettext: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(packages []string) error {
+	e := extract.NewExtractor()
+	if *noDefaultKeywords {
+		e.Keywords, e.TemplateKeywords = nil, nil
+	}
+	e.Keywords = append(e.Keywords, keywords...)
+	e.TemplateKeywords = append(e.TemplateKeywords, templateKeywords...)
+	e.CommentTag = *commentTag
+	h := gettext.CatalogHeader(e.Header)
+	h.Set("Project-Id-Version", *packageName+" "+*packageVersion)
+	h.Set("Report-Msgid-Bugs-To", *bugsAddress)
+	h.Set("POT-Creation-Date", time.Now().Format(gettext.HeaderDateFormat))
+	if len(packages) == 0 {
+		packages = []string{"."}
+	}
+	for _, pkg := range packages {
+		files, err := sourceFiles(pkg)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if isTemplateFile(file) {
+				err = e.ExtractTemplate(file, nil)
+			} else {
+				err = e.ExtractGo(file, nil)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if *output == "-" {
+		return gettext.WritePo(os.Stdout, e.Iter())
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := gettext.WritePo(f, e.Iter()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// sourceFiles returns the Go and template files of a package directory, sorted by name.
+// With a "/..." suffix, files of all the packages under the directory are
+// returned.
+func sourceFiles(pkg string) ([]string, error) {
+	var files []string
+	if dir := strings.TrimSuffix(pkg, "/..."); dir != pkg {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if path != dir && skipDir(info.Name()) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if isSourceFile(info.Name()) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		return files, err
+	}
+	infos, err := ioutil.ReadDir(pkg)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		if !info.IsDir() && isSourceFile(info.Name()) {
+			files = append(files, filepath.Join(pkg, info.Name()))
+		}
+	}
+	return files, nil
+}
+
+// skipDir reports whether a directory is ignored by the go tool.
+func skipDir(name string) bool {
+	return name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// isSourceFile reports whether a file should be extracted.
+func isSourceFile(name string) bool {
+	if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+		return false
+	}
+	if isTemplateFile(name) {
+		return true
+	}
+	return strings.HasSuffix(name, ".go") && (*tests || !strings.HasSuffix(name, "_test.go"))
+}
+
+// isTemplateFile reports whether a file is a template.
+func isTemplateFile(name string) bool {
+	for _, ext := range strings.Split(*templateExts, ",") {
+		if ext = strings.TrimSpace(ext); ext != "" && strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"os"
+	"strings"
+)
+
+// EnvLocales returns the locales requested by the environment for
+// messages, in order of preference, following the rules of GNU gettext:
+//
+//   - the locale is the first non-empty value of LC_ALL, LC_MESSAGES and
+//     LANG;
+//   - if the locale is unset, or is "C" or "POSIX", messages are not
+//     translated, and no locales are returned;
+//   - otherwise, if LANGUAGE is set, its colon-separated list of locales is
+//     returned;
+//   - otherwise, the locale is returned.
+//
+// Locales are returned as given, usually POSIX names such as "pt_BR.UTF-8".
+// Use them with Bundle.Translator, which also falls back to their parents.
+func EnvLocales() []string {
+	return envLocales(os.Getenv)
+}
+
+// envLocales is EnvLocales, using getenv to read the environment.
+func envLocales(getenv func(string) string) []string {
+	var locale string
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale = getenv(name); locale != "" {
+			break
+		}
+	}
+	if locale == "" || isCLocale(locale) {
+		return nil
+	}
+	var locales []string
+	for _, l := range strings.Split(getenv("LANGUAGE"), ":") {
+		if l != "" {
+			locales = append(locales, l)
+		}
+	}
+	if locales == nil {
+		locales = []string{locale}
+	}
+	return locales
+}
+
+// isCLocale reports whether a locale is the C or POSIX locale, with an
+// optional codeset or modifier, such as "C.UTF-8".
+func isCLocale(locale string) bool {
+	if idx := strings.IndexAny(locale, ".@"); idx != -1 {
+		locale = locale[:idx]
+	}
+	return locale == "C" || locale == "POSIX"
+}
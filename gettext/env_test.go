@@ -0,0 +1,35 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvLocales(t *testing.T) {
+	tests := []struct {
+		env      map[string]string
+		expected []string
+	}{
+		{map[string]string{}, nil},
+		{map[string]string{"LANG": "pt_BR.UTF-8"}, []string{"pt_BR.UTF-8"}},
+		{map[string]string{"LANG": "de_DE", "LC_MESSAGES": "fr_FR"}, []string{"fr_FR"}},
+		{map[string]string{"LANG": "de_DE", "LC_MESSAGES": "fr_FR", "LC_ALL": "sr_RS@latin"}, []string{"sr_RS@latin"}},
+		{map[string]string{"LANG": "de_DE", "LANGUAGE": "pt_BR:pt::en"}, []string{"pt_BR", "pt", "en"}},
+		{map[string]string{"LANG": "de_DE", "LANGUAGE": ":"}, []string{"de_DE"}},
+		// LANGUAGE is ignored without a locale, or with the C locale.
+		{map[string]string{"LANGUAGE": "pt_BR"}, nil},
+		{map[string]string{"LANG": "de_DE", "LC_ALL": "C", "LANGUAGE": "pt_BR"}, nil},
+		{map[string]string{"LANG": "POSIX", "LANGUAGE": "pt_BR"}, nil},
+		{map[string]string{"LANG": "C.UTF-8", "LANGUAGE": "pt_BR"}, nil},
+	}
+	for _, test := range tests {
+		got := envLocales(func(name string) string { return test.env[name] })
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%v: expected %q, got %q.", test.env, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package extract finds translatable strings in Go source code and templates,
+and builds gettext templates (POT files) from them, like GNU xgettext does
+for other languages.
+
+Translatable strings are the string literals passed to keyword functions,
+such as the lookup methods of gettext.Catalog:
+
+	e := extract.NewExtractor()
+	if err := e.ExtractGo("main.go", nil); err != nil {
+		// handle error
+	}
+	if err := e.ExtractTemplate("index.html", nil); err != nil {
+		// handle error
+	}
+	err := gettext.WritePo(w, e.Iter())
+
+Each message records the source lines where it was found, comments for
+translators that precede the call, and the go-format flag if the message
+contains formatting verbs.
+*/
+package extract
+
+import (
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/i18n/gettext"
+)
+
+// Keyword describes a function or method whose calls mark translatable
+// strings. Argument positions start at 1.
+type Keyword struct {
+	Name     string // function or method name
+	Ctxt     int    // position of the msgctxt argument, or 0
+	Id       int    // position of the msgid argument
+	IdPlural int    // position of the msgid_plural argument, or 0
+}
+
+// GoKeywords are the keywords used by default to extract strings from Go
+// code: the lookup methods of gettext.Catalog.
+var GoKeywords = []Keyword{
+	{Name: "Singular", Id: 1},
+	{Name: "ContextSingular", Ctxt: 1, Id: 2},
+	{Name: "Plural", Id: 1, IdPlural: 2},
+	{Name: "ContextPlural", Ctxt: 1, Id: 2, IdPlural: 3},
+}
+
+// ParseKeyword parses a keyword specification in the format used by the
+// --keyword option of GNU xgettext: the function name, optionally followed
+// by a colon and the argument positions, such as "Plural:1,2" or
+// "ContextSingular:1c,2". The context position has the "c" suffix. If no
+// positions are given, the msgid is the first argument.
+func ParseKeyword(spec string) (Keyword, error) {
+	k := Keyword{Name: spec, Id: 1}
+	idx := strings.Index(spec, ":")
+	if idx == -1 {
+		if spec == "" {
+			return k, keywordError(spec)
+		}
+		return k, nil
+	}
+	k.Name, k.Id = spec[:idx], 0
+	if k.Name == "" {
+		return k, keywordError(spec)
+	}
+	for _, arg := range strings.Split(spec[idx+1:], ",") {
+		isCtxt := strings.HasSuffix(arg, "c")
+		n, err := strconv.Atoi(strings.TrimSuffix(arg, "c"))
+		if err != nil || n < 1 {
+			return k, keywordError(spec)
+		}
+		switch {
+		case isCtxt && k.Ctxt == 0:
+			k.Ctxt = n
+		case !isCtxt && k.Id == 0:
+			k.Id = n
+		case !isCtxt && k.IdPlural == 0:
+			k.IdPlural = n
+		default:
+			return k, keywordError(spec)
+		}
+	}
+	if k.Id == 0 {
+		return k, keywordError(spec)
+	}
+	return k, nil
+}
+
+func keywordError(spec string) error {
+	return fmt.Errorf("Invalid keyword specification %q.", spec)
+}
+
+// String returns the keyword specification, as accepted by ParseKeyword.
+func (k Keyword) String() string {
+	var args []string
+	if k.Ctxt != 0 {
+		args = append(args, strconv.Itoa(k.Ctxt)+"c")
+	}
+	args = append(args, strconv.Itoa(k.Id))
+	if k.IdPlural != 0 {
+		args = append(args, strconv.Itoa(k.IdPlural))
+	}
+	return k.Name + ":" + strings.Join(args, ",")
+}
+
+// args returns the amount of arguments needed by the keyword.
+func (k Keyword) args() int {
+	n := k.Id
+	if k.Ctxt > n {
+		n = k.Ctxt
+	}
+	if k.IdPlural > n {
+		n = k.IdPlural
+	}
+	return n
+}
+
+// ----------------------------------------------------------------------------
+
+// NewExtractor returns a new extractor using the default keywords, and a
+// template header with placeholders like the ones written by GNU xgettext.
+func NewExtractor() *Extractor {
+	return &Extractor{
+		Keywords:         GoKeywords,
+		TemplateKeywords: TemplateKeywords,
+		CommentTag:       "TRANSLATORS:",
+		Header: textproto.MIMEHeader{
+			"Project-Id-Version":        {"PACKAGE VERSION"},
+			"Report-Msgid-Bugs-To":      {""},
+			"Pot-Creation-Date":         {""},
+			"Po-Revision-Date":          {"YEAR-MO-DA HO:MI+ZONE"},
+			"Last-Translator":           {"FULL NAME <EMAIL@ADDRESS>"},
+			"Language-Team":             {"LANGUAGE <LL@li.org>"},
+			"Language":                  {""},
+			"Mime-Version":              {"1.0"},
+			"Content-Type":              {"text/plain; charset=CHARSET"},
+			"Content-Transfer-Encoding": {"8bit"},
+		},
+		msgs: map[string]*gettext.Message{},
+	}
+}
+
+// Extractor collects translatable strings from source files.
+//
+// Messages found more than once are merged, keeping all their references
+// and comments.
+type Extractor struct {
+	Keywords         []Keyword            // functions that mark translatable strings in Go code
+	TemplateKeywords []Keyword            // functions that mark translatable strings in templates
+	LeftDelim        string               // left delimiter of templates, or "" for "{{"
+	RightDelim       string               // right delimiter of templates, or "" for "}}"
+	CommentTag       string               // prefix of comments for translators, or "" for none
+	Header           textproto.MIMEHeader // header of the template
+	msgs             map[string]*gettext.Message
+	keys             []string // message keys in order of appearance
+}
+
+// Iter returns an iterator over the template header and the extracted
+// messages, in order of appearance. Write it with gettext.WritePo to
+// create a POT file.
+func (e *Extractor) Iter() gettext.Iterator {
+	header := gettext.CatalogHeader{}
+	for key, values := range e.Header {
+		header[key] = values
+	}
+	for _, key := range e.keys {
+		if e.msgs[key].IdPlural != nil && header.Get("Plural-Forms") == "" {
+			header.Set("Plural-Forms", "nplurals=INTEGER; plural=EXPRESSION;")
+		}
+	}
+	msgs := []*gettext.Message{{
+		Id:  []byte{},
+		Str: header.Bytes(),
+		Meta: &gettext.MessageMeta{
+			TranslatorComments: [][]byte{
+				[]byte("SOME DESCRIPTIVE TITLE."),
+				[]byte("Copyright (C) YEAR THE PACKAGE'S COPYRIGHT HOLDER"),
+				[]byte("This file is distributed under the same license as the PACKAGE package."),
+				[]byte("FIRST AUTHOR <EMAIL@ADDRESS>, YEAR."),
+				[]byte(""),
+			},
+			Flags: [][]byte{[]byte("fuzzy")},
+		},
+	}}
+	for _, key := range e.keys {
+		msgs = append(msgs, e.msgs[key])
+	}
+	return &messageIterator{msgs: msgs}
+}
+
+// add adds a message found in the source code. ref is the file and line
+// where it was found.
+func (e *Extractor) add(ctxt *string, id, idPlural string, hasPlural bool, ref string, comments []string) {
+	key := id
+	if ctxt != nil {
+		key = *ctxt + "\x04" + id
+	}
+	msg, ok := e.msgs[key]
+	if !ok {
+		msg = &gettext.Message{
+			Id:   []byte(id),
+			Str:  []byte{},
+			Meta: &gettext.MessageMeta{},
+		}
+		if ctxt != nil {
+			msg.Ctxt = []byte(*ctxt)
+		}
+		e.msgs[key] = msg
+		e.keys = append(e.keys, key)
+	}
+	if hasPlural && msg.IdPlural == nil {
+		msg.IdPlural = []byte(idPlural)
+		msg.Str = nil
+		msg.StrPlural = [][]byte{{}, {}}
+	}
+	meta := msg.Meta
+	meta.References = appendUnique(meta.References, ref)
+	for _, c := range comments {
+		meta.ExtractedComments = appendUnique(meta.ExtractedComments, c)
+	}
+	if isGoFormat(id) || isGoFormat(idPlural) {
+		meta.Flags = appendUnique(meta.Flags, "go-format")
+	}
+}
+
+// appendUnique appends s to list, if it is not there yet.
+func appendUnique(list [][]byte, s string) [][]byte {
+	for _, b := range list {
+		if string(b) == s {
+			return list
+		}
+	}
+	return append(list, []byte(s))
+}
+
+// isGoFormat reports whether s contains verbs of the fmt package, such as
+// "%s" or "%[1]*.2f".
+func isGoFormat(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			continue
+		}
+		for i++; i < len(s) && strings.IndexByte("+-# 0", s[i]) != -1; i++ {
+		}
+		for ; i < len(s) && strings.IndexByte("0123456789[].*", s[i]) != -1; i++ {
+		}
+		if i < len(s) && ('a' <= s[i] && s[i] <= 'z' || 'A' <= s[i] && s[i] <= 'Z') {
+			return true
+		}
+	}
+	return false
+}
+
+// ----------------------------------------------------------------------------
+
+// messageIterator iterates over a list of messages.
+type messageIterator struct {
+	msgs []*gettext.Message
+	pos  int
+}
+
+// Size returns the amount of messages provided by the iterator.
+func (i *messageIterator) Size() int {
+	return len(i.msgs)
+}
+
+// Next returns the next message. At the end of the iteration,
+// io.EOF is returned as the error.
+func (i *messageIterator) Next() (*gettext.Message, error) {
+	if i.pos >= len(i.msgs) {
+		return nil, io.EOF
+	}
+	i.pos += 1
+	return i.msgs[i.pos-1], nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package extract
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/i18n/gettext"
+)
+
+func TestParseKeyword(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected Keyword
+	}{
+		{"T", Keyword{Name: "T", Id: 1}},
+		{"TN:1,2", Keyword{Name: "TN", Id: 1, IdPlural: 2}},
+		{"ContextPlural:1c,2,3", Keyword{Name: "ContextPlural", Ctxt: 1, Id: 2, IdPlural: 3}},
+		{"Get:2,1c", Keyword{Name: "Get", Ctxt: 1, Id: 2}},
+	}
+	for _, test := range tests {
+		k, err := ParseKeyword(test.spec)
+		if err != nil {
+			t.Errorf("%q: %v", test.spec, err)
+		} else if k != test.expected {
+			t.Errorf("%q: expected %+v, got %+v.", test.spec, test.expected, k)
+		}
+		if k2, _ := ParseKeyword(k.String()); k2 != k {
+			t.Errorf("%q: expected %+v, got %+v.", k.String(), k, k2)
+		}
+	}
+	for _, spec := range []string{"", ":1", "T:", "T:0", "T:1c", "T:1,2,3", "T:x"} {
+		if _, err := ParseKeyword(spec); err == nil {
+			t.Errorf("Expected error for %q.", spec)
+		}
+	}
+}
+
+var goSource = `package main
+
+func main() {
+	// TRANSLATORS: shown in the title bar.
+	// The name is the user name.
+	title := c.Singular("Hello, %s!", name)
+	open := c.ContextSingular("menu", "Open")
+	files := c.Plural("%d file", "%d files", n, n) // TRANSLATORS: not extracted.
+	sure := c.Singular("100%% " +
+		` + "`sure`" + `)
+	// Not a comment for translators.
+	other := c.Singular(variable)
+	again := T("Hello, %s!")
+	wrong := c.Plural("missing plural")
+}
+`
+
+var goPot = `#. TRANSLATORS: shown in the title bar.
+#. The name is the user name.
+#: main.go:6 main.go:13
+#, go-format
+msgid "Hello, %s!"
+msgstr ""
+
+#: main.go:7
+msgctxt "menu"
+msgid "Open"
+msgstr ""
+
+#: main.go:8
+#, go-format
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] ""
+msgstr[1] ""
+
+#: main.go:9
+msgid "100%% sure"
+msgstr ""
+`
+
+func TestExtractGo(t *testing.T) {
+	e := NewExtractor()
+	e.Keywords = append(e.Keywords, Keyword{Name: "T", Id: 1})
+	if err := e.ExtractGo("main.go", []byte(goSource)); err != nil {
+		t.Fatal(err)
+	}
+	b := new(bytes.Buffer)
+	if err := gettext.WritePo(b, e.Iter()); err != nil {
+		t.Fatal(err)
+	}
+	s := b.String()
+	if idx := strings.Index(s, "\n\n"); idx != -1 {
+		header, body := s[:idx], s[idx+2:]
+		if !strings.Contains(header, `"Plural-Forms: nplurals=INTEGER; plural=EXPRESSION;\n"`) {
+			t.Errorf("Unexpected header:\n%s", header)
+		}
+		s = body
+	}
+	if s != goPot {
+		t.Errorf("Expected:\n%s\nGot:\n%s", goPot, s)
+	}
+	if err := e.ExtractGo("invalid.go", []byte("package")); err == nil {
+		t.Errorf("Expected syntax error.")
+	}
+}
+
+func TestIsGoFormat(t *testing.T) {
+	tests := map[string]bool{
+		"%s":          true,
+		"%[1]*.2f":    true,
+		"100%":        false,
+		"100%% sure":  false,
+		"%-5d items":  true,
+		"no verbs":    false,
+		"%%d literal": false,
+	}
+	for s, expected := range tests {
+		if isGoFormat(s) != expected {
+			t.Errorf("%q: expected %v.", s, expected)
+		}
+	}
+}
+
+func TestExtractorHeader(t *testing.T) {
+	e := NewExtractor()
+	gettext.CatalogHeader(e.Header).Set("Project-Id-Version", "app 1.0")
+	msg, err := e.Iter().Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !msg.HasFlag("fuzzy") || !strings.HasPrefix(string(msg.Str), "Project-Id-Version: app 1.0\n") {
+		t.Errorf("Unexpected header %q.", msg.Str)
+	}
+	if !reflect.DeepEqual(msg.Id, []byte{}) {
+		t.Errorf("Expected an empty msgid, got %q.", msg.Id)
+	}
+}
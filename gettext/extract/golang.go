@@ -0,0 +1,131 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ExtractGo extracts the translatable strings of a Go source file.
+//
+// If src is nil, the file is read from filename; otherwise src is used as
+// the file contents. filename is used in the references of the extracted
+// messages.
+//
+// Only calls with string literals as arguments, or concatenations of them,
+// are extracted. Comments that start with the comment tag and end on the
+// line before a call, or on the same line, are extracted as comments for
+// translators.
+func (e *Extractor) ExtractGo(filename string, src []byte) error {
+	if src == nil {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		src = b
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	keywords := map[string]Keyword{}
+	for _, k := range e.Keywords {
+		keywords[k.Name] = k
+	}
+	// Comments for translators, by the line where they end. Comments that
+	// follow code in the same line belong to that code.
+	comments := map[int]*ast.CommentGroup{}
+	if e.CommentTag != "" {
+		for _, c := range f.Comments {
+			if !strings.HasPrefix(strings.TrimSpace(c.Text()), e.CommentTag) {
+				continue
+			}
+			start := fset.Position(c.Pos()).Offset
+			lineStart := bytes.LastIndex(src[:start], []byte("\n")) + 1
+			if len(bytes.TrimSpace(src[lineStart:start])) == 0 {
+				comments[fset.Position(c.End()).Line] = c
+			}
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		var name string
+		switch fun := call.Fun.(type) {
+		case *ast.Ident:
+			name = fun.Name
+		case *ast.SelectorExpr:
+			name = fun.Sel.Name
+		}
+		k, ok := keywords[name]
+		if !ok || len(call.Args) < k.args() {
+			return true
+		}
+		var ctxt *string
+		var id, idPlural string
+		if k.Ctxt != 0 {
+			s, ok := stringValue(call.Args[k.Ctxt-1])
+			if !ok {
+				return true
+			}
+			ctxt = &s
+		}
+		if id, ok = stringValue(call.Args[k.Id-1]); !ok {
+			return true
+		}
+		if k.IdPlural != 0 {
+			if idPlural, ok = stringValue(call.Args[k.IdPlural-1]); !ok {
+				return true
+			}
+		}
+		pos := fset.Position(call.Pos())
+		var extracted []string
+		for _, line := range []int{pos.Line, pos.Line - 1} {
+			if c := comments[line]; c != nil && c.End() < call.Pos() {
+				extracted = strings.Split(strings.TrimSpace(c.Text()), "\n")
+				break
+			}
+		}
+		ref := fmt.Sprintf("%s:%d", filepath.ToSlash(filename), fset.Position(call.Args[k.Id-1].Pos()).Line)
+		e.add(ctxt, id, idPlural, k.IdPlural != 0, ref, extracted)
+		return true
+	})
+	return nil
+}
+
+// stringValue returns the value of a constant string expression made of
+// string literals.
+func stringValue(expr ast.Expr) (string, bool) {
+	switch x := expr.(type) {
+	case *ast.BasicLit:
+		if x.Kind == token.STRING {
+			s, err := strconv.Unquote(x.Value)
+			return s, err == nil
+		}
+	case *ast.ParenExpr:
+		return stringValue(x.X)
+	case *ast.BinaryExpr:
+		if x.Op == token.ADD {
+			a, ok := stringValue(x.X)
+			if !ok {
+				return "", false
+			}
+			b, ok := stringValue(x.Y)
+			return a + b, ok
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,233 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// TemplateKeywords are the keywords used by default to extract strings
+// from templates: the functions T, TC, TN and TNC, with the arguments of
+// Singular, ContextSingular, Plural and ContextPlural.
+var TemplateKeywords = []Keyword{
+	{Name: "T", Id: 1},
+	{Name: "TC", Ctxt: 1, Id: 2},
+	{Name: "TN", Id: 1, IdPlural: 2},
+	{Name: "TNC", Ctxt: 1, Id: 2, IdPlural: 3},
+}
+
+// ExtractTemplate extracts the translatable strings of a text/template or
+// html/template file, using the template keywords and delimiters of the
+// extractor.
+//
+// If src is nil, the file is read from filename; otherwise src is used as
+// the file contents. filename is used in the references of the extracted
+// messages.
+//
+// Strings are extracted from calls with string constants as arguments, such
+// as {{T "Hello"}} or {{"Hello" | T}}. Template comments that start with the
+// comment tag and end on the line before a call, or on the same line, are
+// extracted as comments for translators. Functions other than the keywords
+// don't need to be known.
+func (e *Extractor) ExtractTemplate(filename string, src []byte) error {
+	if src == nil {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		src = b
+	}
+	keywords := map[string]Keyword{}
+	for _, k := range e.TemplateKeywords {
+		keywords[k.Name] = k
+	}
+	trees, err := parseTemplate(filename, string(src), e.LeftDelim, e.RightDelim)
+	if err != nil {
+		return err
+	}
+	x := &templateExtractor{
+		e:        e,
+		src:      src,
+		filename: filepath.ToSlash(filename),
+		keywords: keywords,
+		comments: e.templateComments(src),
+	}
+	// Sort the trees, so that messages are added in a stable order.
+	var names []string
+	for name := range trees {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if root := trees[name].Root; root != nil {
+			x.walk(root)
+		}
+	}
+	return nil
+}
+
+// templateComments returns the comments for translators found in a
+// template, by the line where they end. Comments that follow an action in
+// the same line belong to that action.
+func (e *Extractor) templateComments(src []byte) map[int]string {
+	comments := map[int]string{}
+	if e.CommentTag == "" {
+		return comments
+	}
+	left, right := e.LeftDelim, e.RightDelim
+	if left == "" {
+		left = "{{"
+	}
+	if right == "" {
+		right = "}}"
+	}
+	re := regexp.MustCompile(`(?s)` + regexp.QuoteMeta(left) + `(?:- )?/\*(.*?)\*/(?: -)?` + regexp.QuoteMeta(right))
+	for _, m := range re.FindAllSubmatchIndex(src, -1) {
+		text := strings.TrimSpace(string(src[m[2]:m[3]]))
+		if !strings.HasPrefix(text, e.CommentTag) {
+			continue
+		}
+		lineStart := bytes.LastIndex(src[:m[0]], []byte("\n")) + 1
+		if len(bytes.TrimSpace(src[lineStart:m[0]])) == 0 {
+			comments[lineAt(src, m[1])] = text
+		}
+	}
+	return comments
+}
+
+// templateBuiltins declares the predefined template functions. The parser
+// only checks that functions are declared with non-nil values.
+var templateBuiltins = map[string]interface{}{
+	"and": true, "call": true, "html": true, "index": true, "js": true,
+	"len": true, "not": true, "or": true, "print": true, "printf": true,
+	"println": true, "urlquery": true, "eq": true, "ge": true, "gt": true,
+	"le": true, "lt": true, "ne": true, "slice": true,
+}
+
+// ----------------------------------------------------------------------------
+
+// templateExtractor extracts strings from the parse trees of a template.
+type templateExtractor struct {
+	e        *Extractor
+	src      []byte
+	filename string
+	keywords map[string]Keyword
+	comments map[int]string
+}
+
+// walk extracts strings from a node and its children.
+func (x *templateExtractor) walk(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n != nil {
+			for _, c := range n.Nodes {
+				x.walk(c)
+			}
+		}
+	case *parse.ActionNode:
+		x.walk(n.Pipe)
+	case *parse.IfNode:
+		x.walkBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		x.walkBranch(&n.BranchNode)
+	case *parse.WithNode:
+		x.walkBranch(&n.BranchNode)
+	case *parse.TemplateNode:
+		x.walk(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for i, cmd := range n.Cmds {
+			var prev *parse.CommandNode
+			if i > 0 {
+				prev = n.Cmds[i-1]
+			}
+			x.command(cmd, prev)
+			for _, arg := range cmd.Args {
+				x.walk(arg)
+			}
+		}
+	}
+}
+
+func (x *templateExtractor) walkBranch(n *parse.BranchNode) {
+	x.walk(n.Pipe)
+	x.walk(n.List)
+	x.walk(n.ElseList)
+}
+
+// command extracts the strings of a command calling a keyword function.
+// prev is the previous command in the pipeline, if any, whose result is
+// passed as the last argument.
+func (x *templateExtractor) command(cmd, prev *parse.CommandNode) {
+	if len(cmd.Args) == 0 {
+		return
+	}
+	ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+	if !ok {
+		return
+	}
+	k, ok := x.keywords[ident.Ident]
+	if !ok {
+		return
+	}
+	args := cmd.Args[1:]
+	if prev != nil && len(prev.Args) == 1 {
+		args = append(args[:len(args):len(args)], prev.Args[0])
+	}
+	if len(args) < k.args() {
+		return
+	}
+	var ctxt *string
+	var id, idPlural string
+	if k.Ctxt != 0 {
+		s, ok := args[k.Ctxt-1].(*parse.StringNode)
+		if !ok {
+			return
+		}
+		ctxt = &s.Text
+	}
+	s, ok := args[k.Id-1].(*parse.StringNode)
+	if !ok {
+		return
+	}
+	id = s.Text
+	if k.IdPlural != 0 {
+		p, ok := args[k.IdPlural-1].(*parse.StringNode)
+		if !ok {
+			return
+		}
+		idPlural = p.Text
+	}
+	line := lineAt(x.src, int(ident.Position()))
+	var extracted []string
+	for _, l := range []int{line, line - 1} {
+		if c, ok := x.comments[l]; ok {
+			extracted = strings.Split(c, "\n")
+			for i := range extracted {
+				extracted[i] = strings.TrimSpace(extracted[i])
+			}
+			break
+		}
+	}
+	ref := fmt.Sprintf("%s:%d", x.filename, lineAt(x.src, int(s.Position())))
+	x.e.add(ctxt, id, idPlural, k.IdPlural != 0, ref, extracted)
+}
+
+// lineAt returns the line number of the given offset.
+func lineAt(src []byte, offset int) int {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	return 1 + bytes.Count(src[:offset], []byte("\n"))
+}
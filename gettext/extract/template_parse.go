@@ -0,0 +1,24 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.17
+// +build go1.17
+
+package extract
+
+import (
+	"text/template/parse"
+)
+
+// parseTemplate parses a template without checking that its functions are
+// defined, so that templates using unknown functions can be extracted.
+func parseTemplate(name, text, leftDelim, rightDelim string) (map[string]*parse.Tree, error) {
+	trees := map[string]*parse.Tree{}
+	t := parse.New(name)
+	t.Mode = parse.SkipFuncCheck
+	if _, err := t.Parse(text, leftDelim, rightDelim, trees, templateBuiltins); err != nil {
+		return nil, err
+	}
+	return trees, nil
+}
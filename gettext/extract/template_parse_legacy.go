@@ -0,0 +1,40 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !go1.17
+// +build !go1.17
+
+package extract
+
+import (
+	"regexp"
+	"text/template/parse"
+)
+
+// undefinedFunc matches the parse error for an unknown function.
+var undefinedFunc = regexp.MustCompile(`function "([^"]+)" not defined`)
+
+// parseTemplate parses a template, declaring the functions it uses as they
+// are found, so that templates using unknown functions can be extracted.
+//
+// This is the fallback for Go versions before 1.17, which have no
+// parse.SkipFuncCheck: it matches the text of the parse errors, and parses
+// the template again for each unknown function.
+func parseTemplate(name, text, leftDelim, rightDelim string) (map[string]*parse.Tree, error) {
+	funcs := map[string]interface{}{}
+	for {
+		trees, err := parse.Parse(name, text, leftDelim, rightDelim, funcs, templateBuiltins)
+		if err == nil {
+			return trees, nil
+		}
+		m := undefinedFunc.FindStringSubmatch(err.Error())
+		if m == nil {
+			return nil, err
+		}
+		if _, ok := funcs[m[1]]; ok {
+			return nil, err
+		}
+		funcs[m[1]] = true
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package extract
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/i18n/gettext"
+)
+
+var templateSource = `{{define "title"}}{{T "Hello, %s!" .Name}}{{end}}
+<h1>{{template "title" .}}</h1>
+{{/* TRANSLATORS: a menu entry. */}}
+<a>{{TC "menu" "Open"}}</a>
+{{if .Files}}
+  <p>{{TN "%d file" "%d files" (len .Files) (len .Files) | upper}}</p>
+{{else}}
+  <p>{{"No files" | T}}</p>{{/* TRANSLATORS: not extracted. */}}
+{{end}}
+{{range .Items}}{{printf "%s: %s" (TNC "item" "One" "Many" .N) .Name}}{{end}}
+{{T .Dynamic}}
+`
+
+var templatePot = `#. TRANSLATORS: shown in the title bar.
+#: main.go:5 index.html:1
+#, go-format
+msgid "Hello, %s!"
+msgstr ""
+
+#. TRANSLATORS: a menu entry.
+#: index.html:4
+msgctxt "menu"
+msgid "Open"
+msgstr ""
+
+#: index.html:6
+#, go-format
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] ""
+msgstr[1] ""
+
+#: index.html:8
+msgid "No files"
+msgstr ""
+
+#: index.html:10
+msgctxt "item"
+msgid "One"
+msgid_plural "Many"
+msgstr[0] ""
+msgstr[1] ""
+`
+
+func TestExtractTemplate(t *testing.T) {
+	e := NewExtractor()
+	goSource := "package main\n\nfunc main() {\n\t// TRANSLATORS: shown in the title bar.\n\tc.Singular(\"Hello, %s!\", name)\n}\n"
+	if err := e.ExtractGo("main.go", []byte(goSource)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.ExtractTemplate("index.html", []byte(templateSource)); err != nil {
+		t.Fatal(err)
+	}
+	b := new(bytes.Buffer)
+	if err := gettext.WritePo(b, e.Iter()); err != nil {
+		t.Fatal(err)
+	}
+	s := b.String()
+	s = s[strings.Index(s, "\n\n")+2:]
+	if s != templatePot {
+		t.Errorf("Expected:\n%s\nGot:\n%s", templatePot, s)
+	}
+}
+
+func TestExtractTemplateDelims(t *testing.T) {
+	e := NewExtractor()
+	e.LeftDelim, e.RightDelim = "[[", "]]"
+	e.TemplateKeywords = append(e.TemplateKeywords, Keyword{Name: "gettext", Id: 1})
+	src := "[[/* TRANSLATORS: custom. */]]\n{{T \"ignored\"}} [[gettext \"Custom\"]]\n"
+	if err := e.ExtractTemplate("custom.tmpl", []byte(src)); err != nil {
+		t.Fatal(err)
+	}
+	iter := e.Iter()
+	if iter.Size() != 2 {
+		t.Fatalf("Expected 2 messages, got %d.", iter.Size())
+	}
+	iter.Next()
+	msg, _ := iter.Next()
+	if string(msg.Id) != "Custom" || string(msg.Meta.ExtractedComments[0]) != "TRANSLATORS: custom." ||
+		string(msg.Meta.References[0]) != "custom.tmpl:2" {
+		t.Errorf("Unexpected message %q, %+v.", msg.Id, msg.Meta)
+	}
+	if err := e.ExtractTemplate("invalid.tmpl", []byte("[[if]]")); err == nil {
+		t.Errorf("Expected syntax error.")
+	}
+}
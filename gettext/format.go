@@ -0,0 +1,208 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CheckFormat checks that the translations of a message flagged with
+// "go-format" or "c-format" use the same formatting directives as the
+// untranslated strings, like msgfmt --check-format does. Other messages and
+// empty translations are not checked.
+//
+// The translation of a singular message must use all the arguments of the
+// msgid, with the same verbs. Plural translations are checked against
+// msgid_plural, but they may omit arguments: some languages don't show the
+// number in all plural forms.
+func CheckFormat(msg *Message) error {
+	var c bool
+	switch {
+	case msg.HasFlag("go-format"):
+	case msg.HasFlag("c-format"):
+		c = true
+	default:
+		return nil
+	}
+	if msg.IdPlural == nil {
+		return checkFormat(msg.Id, msg.Str, c, false)
+	}
+	for _, str := range msg.StrPlural {
+		if err := checkFormat(msg.IdPlural, str, c, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFormat compares the directives of a translation with the ones of
+// the untranslated string.
+func checkFormat(id, str []byte, c, partial bool) error {
+	if len(str) == 0 {
+		return nil
+	}
+	idArgs, err := formatArgs(id, c)
+	if err != nil {
+		return fmt.Errorf("Invalid format in msgid %q: %s.", id, err)
+	}
+	strArgs, err := formatArgs(str, c)
+	if err != nil {
+		return fmt.Errorf("Invalid format in translation %q: %s.", str, err)
+	}
+	var args []int
+	for arg := range idArgs {
+		args = append(args, arg)
+	}
+	for arg := range strArgs {
+		if _, ok := idArgs[arg]; !ok {
+			args = append(args, arg)
+		}
+	}
+	sort.Ints(args)
+	for _, arg := range args {
+		v1, ok1 := idArgs[arg]
+		v2, ok2 := strArgs[arg]
+		switch {
+		case !ok1:
+			return fmt.Errorf("Translation %q uses argument %d, which is not in msgid %q.", str, arg, id)
+		case !ok2 && !partial:
+			return fmt.Errorf("Translation %q doesn't use argument %d of msgid %q.", str, arg, id)
+		case ok2 && v1 != v2:
+			return fmt.Errorf("Translation %q uses %%%c for argument %d, but msgid %q uses %%%c.", str, v2, arg, id, v1)
+		}
+	}
+	return nil
+}
+
+// formatArgs returns the verbs of a format string by argument number,
+// starting at 1. Widths and precisions given as arguments use the '*' verb.
+//
+// Go strings follow the syntax of the fmt package, including explicit
+// argument indexes such as "%[2]d". C strings follow the syntax of printf,
+// including positions such as "%2$d".
+func formatArgs(s []byte, c bool) (map[int]byte, error) {
+	args := map[int]byte{}
+	arg := 1
+	set := func(n int, verb byte) error {
+		if v, ok := args[n]; ok && v != verb {
+			return fmt.Errorf("argument %d is used as %%%c and %%%c", n, v, verb)
+		}
+		args[n] = verb
+		return nil
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			continue
+		}
+		i++
+		if i < len(s) && s[i] == '%' {
+			continue
+		}
+		// C position: "%n$".
+		if c {
+			if n, j := parseNumber(s, i); j < len(s) && j > i && s[j] == '$' {
+				arg, i = n, j+1
+			}
+		}
+		// Flags.
+		for i < len(s) && isFormatFlag(s[i], c) {
+			i++
+		}
+		// Width and precision.
+		for k := 0; k < 2; k++ {
+			if k == 1 {
+				if i >= len(s) || s[i] != '.' {
+					break
+				}
+				i++
+			}
+			if !c {
+				if n, j, ok := parseIndex(s, i); ok {
+					arg, i = n, j
+				}
+			}
+			if i < len(s) && s[i] == '*' {
+				i++
+				if c {
+					if n, j := parseNumber(s, i); j < len(s) && j > i && s[j] == '$' {
+						arg, i = n, j+1
+					}
+				}
+				if err := set(arg, '*'); err != nil {
+					return nil, err
+				}
+				arg++
+			} else {
+				_, i = parseNumber(s, i)
+			}
+		}
+		if !c {
+			if n, j, ok := parseIndex(s, i); ok {
+				arg, i = n, j
+			}
+		} else {
+			// Length modifiers.
+			for i < len(s) && isLengthModifier(s[i]) {
+				i++
+			}
+		}
+		if i >= len(s) {
+			return nil, fmt.Errorf("missing verb at end of string")
+		}
+		if !('a' <= s[i] && s[i] <= 'z' || 'A' <= s[i] && s[i] <= 'Z') {
+			return nil, fmt.Errorf("invalid verb %q", s[i])
+		}
+		if err := set(arg, s[i]); err != nil {
+			return nil, err
+		}
+		arg++
+	}
+	return args, nil
+}
+
+// parseNumber parses a decimal number at s[i:], returning it and the index
+// after it. If there is no number, i is returned.
+func parseNumber(s []byte, i int) (int, int) {
+	n := 0
+	for ; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+		n = n*10 + int(s[i]-'0')
+		if n > 1e6 {
+			n = 1e6
+		}
+	}
+	return n, i
+}
+
+// parseIndex parses an explicit argument index of the fmt package, such
+// as "[2]", at s[i:].
+func parseIndex(s []byte, i int) (int, int, bool) {
+	if i >= len(s) || s[i] != '[' {
+		return 0, i, false
+	}
+	n, j := parseNumber(s, i+1)
+	if j == i+1 || j >= len(s) || s[j] != ']' || n < 1 {
+		return 0, i, false
+	}
+	return n, j + 1, true
+}
+
+func isFormatFlag(b byte, c bool) bool {
+	switch b {
+	case '-', '+', '#', ' ', '0':
+		return true
+	case '\'', 'I':
+		return c
+	}
+	return false
+}
+
+func isLengthModifier(b byte) bool {
+	switch b {
+	case 'h', 'l', 'L', 'q', 'j', 'z', 't':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatArgs(t *testing.T) {
+	tests := []struct {
+		s        string
+		c        bool
+		expected map[int]byte
+	}{
+		{"100%% sure", false, map[int]byte{}},
+		{"%s has %d files", false, map[int]byte{1: 's', 2: 'd'}},
+		{"%[2]d files for %[1]s", false, map[int]byte{1: 's', 2: 'd'}},
+		{"%-*.*f %x", false, map[int]byte{1: '*', 2: '*', 3: 'f', 4: 'x'}},
+		{"%[3]*.[2]*[1]f", false, map[int]byte{1: 'f', 2: '*', 3: '*'}},
+		{"%2$d files for %1$s", true, map[int]byte{1: 's', 2: 'd'}},
+		{"%05ld %'.2Lf %zu", true, map[int]byte{1: 'd', 2: 'f', 3: 'u'}},
+	}
+	for _, test := range tests {
+		args, err := formatArgs([]byte(test.s), test.c)
+		if err != nil {
+			t.Errorf("%q: %v", test.s, err)
+		} else if !reflect.DeepEqual(args, test.expected) {
+			t.Errorf("%q: expected %v, got %v.", test.s, test.expected, args)
+		}
+	}
+	for _, s := range []string{"50%", "%[1]d %[1]s", "%é"} {
+		if _, err := formatArgs([]byte(s), false); err == nil {
+			t.Errorf("%q: expected error.", s)
+		}
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	goFormat := &MessageMeta{Flags: [][]byte{[]byte("go-format")}}
+	cFormat := &MessageMeta{Flags: [][]byte{[]byte("c-format")}}
+	tests := []struct {
+		msg   *Message
+		valid bool
+	}{
+		{&Message{Id: []byte("%s: %d"), Str: []byte("%[2]d: %[1]s"), Meta: goFormat}, true},
+		{&Message{Id: []byte("%s: %d"), Str: []byte("%s: %s"), Meta: goFormat}, false},
+		{&Message{Id: []byte("%s: %d"), Str: []byte("%s"), Meta: goFormat}, false},
+		{&Message{Id: []byte("%s"), Str: []byte("%s %d"), Meta: goFormat}, false},
+		{&Message{Id: []byte("%s"), Str: []byte(""), Meta: goFormat}, true},
+		{&Message{Id: []byte("%s: %d"), Str: []byte("%s"), Meta: nil}, true},
+		{&Message{Id: []byte("%s: %d"), Str: []byte("%2$d: %1$s"), Meta: cFormat}, true},
+		{&Message{Id: []byte("%s: %d"), Str: []byte("%2$s: %1$s"), Meta: cFormat}, false},
+		{&Message{
+			Id: []byte("one file"), IdPlural: []byte("%d files"),
+			StrPlural: [][]byte{[]byte("un fichero"), []byte("%d ficheros")}, Meta: goFormat,
+		}, true},
+		{&Message{
+			Id: []byte("one file"), IdPlural: []byte("%d files"),
+			StrPlural: [][]byte{[]byte("%s fichero"), []byte("%d ficheros")}, Meta: goFormat,
+		}, false},
+	}
+	for i, test := range tests {
+		if err := CheckFormat(test.msg); (err == nil) != test.valid {
+			t.Errorf("%d: expected valid=%v, got %v.", i, test.valid, err)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// SafeHTMLFlag is the flag of messages whose translations are trusted to
+// contain HTML markup, such as:
+//
+//	#, safe-html
+//	msgid "Read the <a href=\"%s\">terms</a>."
+//	msgstr "Lisez les <a href=\"%s\">conditions</a>."
+//
+// Their placeholders can appear in text and in attribute values. The
+// arguments are escaped by html/template for the context of their
+// placeholder, so a javascript: URL given for the href above is rejected.
+const SafeHTMLFlag = "safe-html"
+
+// Localizer looks up translated messages. It is implemented by Catalog,
+// MoCatalog and Translator.
+type Localizer interface {
+	Singular(key string, args ...interface{}) string
+	ContextSingular(ctxt, key string, args ...interface{}) string
+	Plural(key, keyPlural string, n int, args ...interface{}) string
+	ContextPlural(ctxt, key, keyPlural string, n int, args ...interface{}) string
+}
+
+// flagLookup is implemented by localizers that keep message flags.
+type flagLookup interface {
+	// hasFlag reports whether the message that answers a lookup of key
+	// has the given flag. plural tells if the lookup is for the plural
+	// form for n.
+	hasFlag(key msgKey, plural bool, n int, flag string) bool
+}
+
+// TextFuncMap returns the functions T, TC, TN and TNC for text/template,
+// which look up messages in l with the arguments of Singular,
+// ContextSingular, Plural and ContextPlural:
+//
+//	{{T "Hello, %s!" .Name}}
+//	{{TC "door" "Open"}}
+//	{{TN "%d file" "%d files" .Count .Count}}
+//	{{TNC "disk" "%d file" "%d files" .Count .Count}}
+//
+// The message can also be piped, as in {{"Hello" | T}}. These are the
+// template keywords used by the extract package.
+func TextFuncMap(l Localizer) texttemplate.FuncMap {
+	f := &templateFuncs{l: l}
+	return texttemplate.FuncMap{
+		"T":   f.textSingular,
+		"TC":  f.textContextSingular,
+		"TN":  f.textPlural,
+		"TNC": f.textContextPlural,
+	}
+}
+
+// HTMLFuncMap returns the functions T, TC, TN and TNC for html/template,
+// like TextFuncMap does for text/template.
+//
+// Translations are escaped by html/template as any other string, unless
+// their message has the SafeHTMLFlag flag. Then the translation is trusted
+// as HTML, and the arguments used to format it are escaped instead, as
+// html/template does for the context where each placeholder is: text,
+// attribute value, URL, script or style. Values of the html/template
+// types, such as template.HTML, are used as is where html/template allows
+// them. If the translation is not valid as a template, for instance with a
+// placeholder inside a tag name, it is escaped as any other string. Flags
+// are only known by catalogs read from PO files, and by translators built
+// from them.
+func HTMLFuncMap(l Localizer) htmltemplate.FuncMap {
+	f := &templateFuncs{l: l}
+	return htmltemplate.FuncMap{
+		"T":   f.htmlSingular,
+		"TC":  f.htmlContextSingular,
+		"TN":  f.htmlPlural,
+		"TNC": f.htmlContextPlural,
+	}
+}
+
+// templateFuncs implements the template functions for a localizer.
+type templateFuncs struct {
+	l Localizer
+}
+
+func (f *templateFuncs) textSingular(key string, args ...interface{}) string {
+	return f.l.Singular(key, args...)
+}
+
+func (f *templateFuncs) textContextSingular(ctxt, key string, args ...interface{}) string {
+	return f.l.ContextSingular(ctxt, key, args...)
+}
+
+func (f *templateFuncs) textPlural(key, keyPlural string, n interface{}, args ...interface{}) (string, error) {
+	i, err := toInt(n)
+	if err != nil {
+		return "", err
+	}
+	return f.l.Plural(key, keyPlural, i, args...), nil
+}
+
+func (f *templateFuncs) textContextPlural(ctxt, key, keyPlural string, n interface{}, args ...interface{}) (string, error) {
+	i, err := toInt(n)
+	if err != nil {
+		return "", err
+	}
+	return f.l.ContextPlural(ctxt, key, keyPlural, i, args...), nil
+}
+
+func (f *templateFuncs) htmlSingular(key string, args ...interface{}) interface{} {
+	return htmlText(f.isSafe(msgKey{id: key}, false, 0), f.l.Singular(key), args)
+}
+
+func (f *templateFuncs) htmlContextSingular(ctxt, key string, args ...interface{}) interface{} {
+	return htmlText(f.isSafe(msgKey{ctxt, true, key}, false, 0), f.l.ContextSingular(ctxt, key), args)
+}
+
+func (f *templateFuncs) htmlPlural(key, keyPlural string, n interface{}, args ...interface{}) (interface{}, error) {
+	i, err := toInt(n)
+	if err != nil {
+		return nil, err
+	}
+	return htmlText(f.isSafe(msgKey{id: key}, true, i), f.l.Plural(key, keyPlural, i), args), nil
+}
+
+func (f *templateFuncs) htmlContextPlural(ctxt, key, keyPlural string, n interface{}, args ...interface{}) (interface{}, error) {
+	i, err := toInt(n)
+	if err != nil {
+		return nil, err
+	}
+	return htmlText(f.isSafe(msgKey{ctxt, true, key}, true, i), f.l.ContextPlural(ctxt, key, keyPlural, i), args), nil
+}
+
+// isSafe reports whether the message that answers a lookup has the
+// SafeHTMLFlag flag.
+func (f *templateFuncs) isSafe(key msgKey, plural bool, n int) bool {
+	l, ok := f.l.(flagLookup)
+	return ok && l.hasFlag(key, plural, n, SafeHTMLFlag)
+}
+
+// htmlText formats the unformatted translation s. If safe is set, the
+// result is trusted as HTML when s is a valid html/template template once
+// its placeholders are replaced by actions. Otherwise, a plain string is
+// returned, which html/template escapes.
+func htmlText(safe bool, s string, args []interface{}) interface{} {
+	if safe {
+		if t := safeTemplate(s); t != nil {
+			b := new(bytes.Buffer)
+			if err := t.tmpl.Execute(b, t.values(args)); err == nil {
+				return htmltemplate.HTML(b.String())
+			}
+		}
+	}
+	return format(s, args)
+}
+
+// ----------------------------------------------------------------------------
+
+// maxHTMLTemplates is the amount of templates kept by htmlTemplates. The
+// cache is emptied when it is full.
+const maxHTMLTemplates = 1000
+
+// htmlTemplates caches the templates built from translations by
+// safeTemplate, including nil ones for invalid translations.
+var htmlTemplates = struct {
+	sync.Mutex
+	m map[string]*htmlTemplate
+}{m: map[string]*htmlTemplate{}}
+
+// htmlTemplate is a translation with placeholders converted to an
+// html/template template, which executes with the values of the
+// placeholders as data.
+type htmlTemplate struct {
+	tmpl  *htmltemplate.Template
+	verbs []formatVerb
+}
+
+// formatVerb is a placeholder of a translation.
+type formatVerb struct {
+	spec string // format without argument index, such as "%5.2f"
+	arg  int    // index of the argument
+}
+
+// safeTemplate returns the template for the translation s, or nil if s is
+// not valid as a template.
+func safeTemplate(s string) *htmlTemplate {
+	htmlTemplates.Lock()
+	t, ok := htmlTemplates.m[s]
+	htmlTemplates.Unlock()
+	if ok {
+		return t
+	}
+	t = newHTMLTemplate(s)
+	htmlTemplates.Lock()
+	if len(htmlTemplates.m) >= maxHTMLTemplates {
+		htmlTemplates.m = map[string]*htmlTemplate{}
+	}
+	htmlTemplates.m[s] = t
+	htmlTemplates.Unlock()
+	return t
+}
+
+// newHTMLTemplate converts the translation s to a template, replacing each
+// placeholder by an action that prints its value. It returns nil if s has
+// placeholders that can't be converted, such as ones with a '*' width, or
+// if the template doesn't parse.
+func newHTMLTemplate(s string) *htmlTemplate {
+	t := new(htmlTemplate)
+	src := new(bytes.Buffer)
+	text := func(s string) {
+		// Delimiters in the translation are printed by actions.
+		src.WriteString(strings.Replace(s, "{{", `{{"{{"}}`, -1))
+	}
+	arg, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			continue
+		}
+		text(s[start:i])
+		j := i + 1
+		if j < len(s) && s[j] == '%' {
+			text("%")
+			i, start = j, j+1
+			continue
+		}
+		spec := "%"
+		for ; j < len(s) && strings.IndexByte("+-# 0", s[j]) != -1; j++ {
+			spec += s[j : j+1]
+		}
+		if j < len(s) && s[j] == '[' {
+			end := strings.IndexByte(s[j:], ']')
+			if end == -1 {
+				return nil
+			}
+			n, err := strconv.Atoi(s[j+1 : j+end])
+			if err != nil || n < 1 {
+				return nil
+			}
+			arg, j = n-1, j+end+1
+		}
+		for ; j < len(s) && (s[j] == '.' || '0' <= s[j] && s[j] <= '9'); j++ {
+			spec += s[j : j+1]
+		}
+		if j >= len(s) || !('a' <= s[j] && s[j] <= 'z' || 'A' <= s[j] && s[j] <= 'Z') {
+			return nil
+		}
+		spec += s[j : j+1]
+		fmt.Fprintf(src, "{{index . %d}}", len(t.verbs))
+		t.verbs = append(t.verbs, formatVerb{spec: spec, arg: arg})
+		arg++
+		i, start = j, j+1
+	}
+	text(s[start:])
+	tmpl, err := htmltemplate.New("").Parse(src.String())
+	if err != nil {
+		return nil
+	}
+	t.tmpl = tmpl
+	return t
+}
+
+// values returns the values of the placeholders for the given arguments.
+// Arguments of plain %s and %v placeholders are kept as is, so that
+// html/template escapes them, or trusts them if they have one of its
+// types. Other placeholders are formatted as strings.
+func (t *htmlTemplate) values(args []interface{}) []interface{} {
+	values := make([]interface{}, len(t.verbs))
+	for i, v := range t.verbs {
+		switch {
+		case v.arg >= len(args):
+			// As printed by fmt.
+			values[i] = "%!" + v.spec[len(v.spec)-1:] + "(MISSING)"
+		case v.spec == "%s" || v.spec == "%v":
+			values[i] = args[v.arg]
+		default:
+			values[i] = fmt.Sprintf(v.spec, args[v.arg])
+		}
+	}
+	return values
+}
+
+// toInt converts a number passed to a template function to an int.
+func toInt(n interface{}) (int, error) {
+	v := reflect.ValueOf(n)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int(v.Uint()), nil
+	}
+	return 0, fmt.Errorf("Invalid plural count %v: expected an integer.", n)
+}
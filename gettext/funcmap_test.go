@@ -0,0 +1,147 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"bytes"
+	"errors"
+	htmltemplate "html/template"
+	"strings"
+	"testing"
+	texttemplate "text/template"
+)
+
+var frenchPoData = `msgid ""
+msgstr ""
+"Language: fr\n"
+"Plural-Forms: nplurals=2; plural=(n > 1);\n"
+
+msgid "Hello, %s!"
+msgstr "Bonjour, %s !"
+
+msgid "<b>Bold</b>"
+msgstr "<b>Gras</b>"
+
+#, safe-html
+msgid "Hello, <b>%s</b>!"
+msgstr "Bonjour, <b>%s</b> !"
+
+#, safe-html
+msgctxt "link"
+msgid "Read the <a href=\"%s\">terms</a>."
+msgstr "Lisez les <a href=\"%s\">conditions</a>."
+
+#, safe-html
+msgid "<b>%d</b> file"
+msgid_plural "<b>%d</b> files"
+msgstr[0] "<b>%d</b> fichier"
+msgstr[1] "<b>%d</b> fichiers"
+
+msgctxt "disk"
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d fichier <sur le disque>"
+msgstr[1] "%d fichiers <sur le disque>"
+
+#, safe-html
+msgid "<a title=\"%s\" onclick=\"go(%[1]q)\">No. %d</a>"
+msgstr "<a title=\"%s\" onclick=\"go(%[1]q)\">N° %03d</a>"
+
+#, safe-html
+msgid "<%s>Tag</%s>"
+msgstr "<%s>Balise</%s>"
+
+#, safe-html
+msgid "<a title=\"%s>Broken</a>"
+msgstr "<a title=\"%s>Cassé</a>"
+
+#, safe-html
+msgid "{{Braces}} %s%%"
+msgstr "{{Accolades}} %s %%"
+
+#, safe-html, fuzzy
+msgid "<i>Fuzzy</i>"
+msgstr "<i>Flou</i>"
+`
+
+var _ Localizer = (*MoCatalog)(nil)
+
+func TestTextFuncMap(t *testing.T) {
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(frenchPoData)); err != nil {
+		t.Fatal(err)
+	}
+	src := `{{T "Hello, %s!" .Name}}|{{"<b>Bold</b>" | T}}|{{TN "%d file" "%d files" .Count .Count}}|` +
+		`{{TNC "disk" "%d file" "%d files" .Count .Count}}|{{TC "link" "Missing"}}`
+	tmpl := texttemplate.Must(texttemplate.New("").Funcs(TextFuncMap(c)).Parse(src))
+	b := new(bytes.Buffer)
+	if err := tmpl.Execute(b, map[string]interface{}{"Name": "<Ann>", "Count": int64(2)}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Bonjour, <Ann> !|<b>Gras</b>|2 files|2 fichiers <sur le disque>|Missing"
+	if s := b.String(); s != expected {
+		t.Errorf("Expected %q, got %q.", expected, s)
+	}
+	tmpl = texttemplate.Must(texttemplate.New("").Funcs(TextFuncMap(c)).Parse(`{{TN "a" "b" "x"}}`))
+	if err := tmpl.Execute(b, nil); err == nil {
+		t.Errorf("Expected an error for an invalid count.")
+	}
+}
+
+func TestHTMLFuncMap(t *testing.T) {
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(frenchPoData)); err != nil {
+		t.Fatal(err)
+	}
+	tr := NewTranslator()
+	tr.Add("fr", c)
+	tests := []struct {
+		src, expected string
+	}{
+		// Messages without the flag are escaped.
+		{`{{T "Hello, %s!" .Name}}`, "Bonjour, &lt;Ann&gt; !"},
+		{`{{T "<b>Bold</b>"}}`, "&lt;b&gt;Gras&lt;/b&gt;"},
+		{`<a title="{{T "<b>Bold</b>"}}">`, `<a title="&lt;b&gt;Gras&lt;/b&gt;">`},
+		{`{{TNC "disk" "%d file" "%d files" 1 1}}`, "1 fichier &lt;sur le disque&gt;"},
+		// Safe messages keep their markup, and escape their arguments.
+		{`{{T "Hello, <b>%s</b>!" .Name}}`, "Bonjour, <b>&lt;Ann&gt;</b> !"},
+		{`{{T "Hello, <b>%s</b>!" .HTML}}`, "Bonjour, <b><i>Ann</i></b> !"},
+		{`{{T "Hello, <b>%s</b>!" .Err}}`, "Bonjour, <b>&lt;error&gt;</b> !"},
+		{`{{TC "link" "Read the <a href=\"%s\">terms</a>." .URL}}`, `Lisez les <a href="/terms?a=1&amp;b=%222%22">conditions</a>.`},
+		// Arguments are escaped for the context of their placeholder.
+		{`{{TC "link" "Read the <a href=\"%s\">terms</a>." .JS}}`, `Lisez les <a href="#ZgotmplZ">conditions</a>.`},
+		{`{{T "<a title=\"%s\" onclick=\"go(%[1]q)\">No. %d</a>" .Name 7}}`,
+			`<a title="&lt;Ann&gt;" onclick="go(&#34;\&#34;\u003cAnn\u003e\&#34;&#34;)">N° 007</a>`},
+		{`{{T "{{Braces}} %s%%" .Name}}`, "{{Accolades}} &lt;Ann&gt; %"},
+		{`{{T "<%s>Tag</%s>" "b" "b"}}`, "&lt;b>Balise&lt;/b>"},
+		// Translations that are not valid templates are escaped.
+		{`{{T "<a title=\"%s>Broken</a>" .Name}}`, "&lt;a title=&#34;&lt;Ann&gt;&gt;Cassé&lt;/a&gt;"},
+		{`{{TN "<b>%d</b> file" "<b>%d</b> files" .Count .Count}}`, "<b>3</b> fichiers"},
+		{`{{TN "<b>%d</b> file" "<b>%d</b> files" 1 1}}`, "<b>1</b> fichier"},
+		// Fuzzy messages are not used, and their flags neither.
+		{`{{T "<i>Fuzzy</i>"}}`, "&lt;i&gt;Fuzzy&lt;/i&gt;"},
+	}
+	data := map[string]interface{}{
+		"Name":  "<Ann>",
+		"HTML":  htmltemplate.HTML("<i>Ann</i>"),
+		"Err":   errors.New("<error>"),
+		"URL":   `/terms?a=1&b="2"`,
+		"JS":    "javascript:alert(1)",
+		"Count": 3,
+	}
+	for _, l := range []Localizer{c, tr} {
+		for _, test := range tests {
+			tmpl := htmltemplate.Must(htmltemplate.New("").Funcs(HTMLFuncMap(l)).Parse(test.src))
+			b := new(bytes.Buffer)
+			if err := tmpl.Execute(b, data); err != nil {
+				t.Errorf("%s: %v", test.src, err)
+				continue
+			}
+			if s := b.String(); s != test.expected {
+				t.Errorf("%s: expected %q, got %q.", test.src, test.expected, s)
+			}
+		}
+	}
+}
@@ -0,0 +1,367 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"bytes"
+	"fmt"
+	gofmt "go/format"
+	"go/token"
+	"io"
+	"sort"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// GoOptions are the options used to write catalogs as Go code.
+type GoOptions struct {
+	// Package is the package name of the generated file.
+	Package string
+	// Name is the name of the generated catalog variable, such as
+	// "Polish". Other identifiers of the file start with it, so several
+	// catalogs can be generated in the same package.
+	Name string
+}
+
+// WriteGo writes Go source code to w that embeds the messages provided by
+// iter, so that programs can look them up without reading MO files.
+//
+// The generated file declares a variable with the given name, whose
+// methods Singular, ContextSingular, Plural and ContextPlural behave like
+// the ones of Catalog, so it implements Localizer. Messages are stored in a
+// static table sorted by context and msgid, and the plural rule of the
+// catalog header is compiled to a Go function, so lookups don't allocate
+// memory unless the translation is formatted with arguments. The generated
+// code only depends on the fmt package.
+//
+// Messages are converted to UTF-8, as done by Decode. Obsolete, fuzzy and
+// untranslated messages are not included. An invalid Plural-Forms header
+// is an error.
+func WriteGo(w io.Writer, iter Iterator, opt *GoOptions) error {
+	if opt == nil || !isGoIdentifier(opt.Package) || !isGoIdentifier(opt.Name) {
+		return fmt.Errorf("Invalid Go options: a package and a variable name are required.")
+	}
+	g := &goWriter{
+		name:    opt.Name,
+		prefix:  goPrefix(opt.Name),
+		rule:    DefaultPluralRule,
+		helpers: map[string]bool{},
+	}
+	iter = Decode(iter)
+	for {
+		msg, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := g.add(msg); err != nil {
+			return err
+		}
+	}
+	sort.Sort(goMessages(g.msgs))
+	for i := 1; i < len(g.msgs); i++ {
+		if goCompare(g.msgs[i-1], g.msgs[i]) == 0 {
+			key := string(g.msgs[i].Id)
+			if g.msgs[i].Ctxt != nil {
+				key = contextKey(string(g.msgs[i].Ctxt), key)
+			}
+			return fmt.Errorf("Message key already exists: %q.", key)
+		}
+	}
+	src, err := gofmt.Source(g.source(opt.Package))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// goWriter generates the Go source of a catalog.
+type goWriter struct {
+	name     string          // catalog variable name
+	prefix   string          // prefix of unexported identifiers
+	rule     *PluralRule     // plural rule of the header
+	language string          // Language of the header
+	msgs     []*Message      // translated messages
+	usesN    bool            // whether the plural expression uses n
+	helpers  map[string]bool // helper functions used by the plural expression
+}
+
+// add adds a message, or reads the header.
+func (g *goWriter) add(msg *Message) error {
+	if msg.Meta != nil && msg.Meta.Obsolete {
+		return nil
+	}
+	if msg.Ctxt == nil && len(msg.Id) == 0 {
+		header := bytesToHeader(msg.Str)
+		if forms := header.Get("Plural-Forms"); forms != "" {
+			rule, err := ParsePluralForms(forms)
+			if err != nil {
+				return err
+			}
+			g.rule = rule
+		}
+		g.language = header.Get("Language")
+		return nil
+	}
+	if msg.HasFlag("fuzzy") || !isTranslated(msg) {
+		return nil
+	}
+	g.msgs = append(g.msgs, msg)
+	return nil
+}
+
+// source returns the unformatted Go source of the catalog.
+func (g *goWriter) source(pkg string) []byte {
+	p := g.prefix
+	b := new(bytes.Buffer)
+	fmt.Fprintf(b, "// Code generated by gettext.WriteGo. DO NOT EDIT.\n\n")
+	fmt.Fprintf(b, "package %s\n\nimport \"fmt\"\n\n", pkg)
+	if g.language != "" {
+		fmt.Fprintf(b, "// %s looks up the messages of a compiled gettext catalog for %s.\n", g.name, g.language)
+	} else {
+		fmt.Fprintf(b, "// %s looks up the messages of a compiled gettext catalog.\n", g.name)
+	}
+	fmt.Fprintf(b, "var %s %sCatalog\n\n", g.name, p)
+	fmt.Fprintf(b, "type %sCatalog struct{}\n\n", p)
+	fmt.Fprintf(b, "// Singular returns the translation of key, optionally formatted with args.\n")
+	fmt.Fprintf(b, "func (%sCatalog) Singular(key string, args ...interface{}) string {\n", p)
+	fmt.Fprintf(b, "return %sFormat(%sSingular(\"\", false, key), args)\n}\n\n", p, p)
+	fmt.Fprintf(b, "// ContextSingular is like Singular, for a message with context.\n")
+	fmt.Fprintf(b, "func (%sCatalog) ContextSingular(ctxt, key string, args ...interface{}) string {\n", p)
+	fmt.Fprintf(b, "return %sFormat(%sSingular(ctxt, true, key), args)\n}\n\n", p, p)
+	fmt.Fprintf(b, "// Plural returns the plural translation of key for n, optionally formatted with args.\n")
+	fmt.Fprintf(b, "func (%sCatalog) Plural(key, keyPlural string, n int, args ...interface{}) string {\n", p)
+	fmt.Fprintf(b, "return %sFormat(%sPlural(\"\", false, key, keyPlural, n), args)\n}\n\n", p, p)
+	fmt.Fprintf(b, "// ContextPlural is like Plural, for a message with context.\n")
+	fmt.Fprintf(b, "func (%sCatalog) ContextPlural(ctxt, key, keyPlural string, n int, args ...interface{}) string {\n", p)
+	fmt.Fprintf(b, "return %sFormat(%sPlural(ctxt, true, key, keyPlural, n), args)\n}\n\n", p, p)
+
+	fmt.Fprintf(b, `func %[1]sSingular(ctxt string, hasCtxt bool, key string) string {
+	if m := %[1]sFind(ctxt, hasCtxt, key); m != nil && !m.plural {
+		return m.str[0]
+	}
+	return key
+}
+
+func %[1]sPlural(ctxt string, hasCtxt bool, key, keyPlural string, n int) string {
+	if m := %[1]sFind(ctxt, hasCtxt, key); m != nil && m.plural {
+		if idx := %[1]sPluralIndex(n); idx < len(m.str) && m.str[idx] != "" {
+			return m.str[idx]
+		}
+	}
+	if n == 1 {
+		return key
+	}
+	return keyPlural
+}
+
+func %[1]sFormat(s string, args []interface{}) string {
+	if len(args) == 0 {
+		return s
+	}
+	return fmt.Sprintf(s, args...)
+}
+
+// %[1]sFind returns the message with the given context and msgid, or nil.
+func %[1]sFind(ctxt string, hasCtxt bool, id string) *%[1]sMessage {
+	lo, hi := 0, len(%[1]sMessages)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		m := &%[1]sMessages[mid]
+		switch {
+		case m.hasCtxt != hasCtxt:
+			if hasCtxt {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		case m.ctxt != ctxt:
+			if m.ctxt < ctxt {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		case m.id != id:
+			if m.id < id {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		default:
+			return m
+		}
+	}
+	return nil
+}
+
+`, p)
+	g.writePluralFunc(b)
+
+	fmt.Fprintf(b, "type %sMessage struct {\nctxt string\nhasCtxt bool\nid string\nstr []string\nplural bool\n}\n\n", p)
+	fmt.Fprintf(b, "// %sMessages are sorted by context, messages without context first, and msgid.\n", p)
+	fmt.Fprintf(b, "var %sMessages = [...]%sMessage{\n", p, p)
+	for _, msg := range g.msgs {
+		b.WriteString("{")
+		if msg.Ctxt != nil {
+			fmt.Fprintf(b, "ctxt: %s, hasCtxt: true, ", strconv.Quote(string(msg.Ctxt)))
+		}
+		fmt.Fprintf(b, "id: %s, str: []string{", strconv.Quote(string(msg.Id)))
+		strs := [][]byte{msg.Str}
+		if msg.IdPlural != nil {
+			strs = msg.StrPlural
+		}
+		for i, s := range strs {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(strconv.Quote(string(s)))
+		}
+		b.WriteString("}")
+		if msg.IdPlural != nil {
+			b.WriteString(", plural: true")
+		}
+		b.WriteString("},\n")
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// writePluralFunc writes the function that selects plural forms.
+func (g *goWriter) writePluralFunc(b *bytes.Buffer) {
+	p := g.prefix
+	body := g.goAssign(g.rule.expr)
+	fmt.Fprintf(b, "// %sPluralIndex returns the plural form for n, compiled from\n// %q.\n", p, g.rule.String())
+	b.WriteString("// Negative numbers use the form of their absolute value, as done by\n// gettext.PluralRule.Index.\n")
+	fmt.Fprintf(b, "func %sPluralIndex(n int) int {\n", p)
+	if g.usesN {
+		b.WriteString("v := uint64(n)\nif n < 0 {\nv = uint64(-n)\n}\n")
+	}
+	check := fmt.Sprintf("idx >= %d", g.rule.NPlurals)
+	if g.helpers["Div"] || g.helpers["Mod"] {
+		b.WriteString("ok := true\n")
+		check = "!ok || " + check
+	}
+	fmt.Fprintf(b, "var idx uint64\n%sif %s {\nreturn 0\n}\nreturn int(idx)\n}\n\n", body, check)
+	if g.helpers["Bool"] {
+		fmt.Fprintf(b, "func %sBool(b bool) uint64 {\nif b {\nreturn 1\n}\nreturn 0\n}\n\n", p)
+	}
+	for _, op := range []string{"Div", "Mod"} {
+		if g.helpers[op] {
+			fmt.Fprintf(b, "func %s%s(x, y uint64, ok *bool) uint64 {\n", p, op)
+			fmt.Fprintf(b, "if y == 0 {\n*ok = false\nreturn 0\n}\nreturn x %s y\n}\n\n", map[string]string{"Div": "/", "Mod": "%"}[op])
+		}
+	}
+}
+
+// goAssign returns Go statements assigning the value of e to idx. The
+// conditional operator becomes an if statement.
+func (g *goWriter) goAssign(e *pluralNode) string {
+	if e.op != "?:" {
+		return fmt.Sprintf("idx = %s\n", g.goExpr(e))
+	}
+	s := fmt.Sprintf("if %s {\n%s} else ", g.goBool(e.args[0]), g.goAssign(e.args[1]))
+	if e.args[2].op == "?:" {
+		return s + g.goAssign(e.args[2])
+	}
+	return s + fmt.Sprintf("{\n%s}\n", g.goAssign(e.args[2]))
+}
+
+// goExpr returns a Go expression of type uint64 evaluating e for v, like
+// pluralNode.eval does. If a division by zero happens, ok is set to false.
+func (g *goWriter) goExpr(e *pluralNode) string {
+	switch e.op {
+	case "":
+		return strconv.FormatUint(e.val, 10)
+	case "n":
+		g.usesN = true
+		return "v"
+	case "?:":
+		// A function literal keeps the evaluation of the branches lazy.
+		return fmt.Sprintf("func() uint64 {\nif %s {\nreturn %s\n}\nreturn %s\n}()",
+			g.goBool(e.args[0]), g.goExpr(e.args[1]), g.goExpr(e.args[2]))
+	case "!", "&&", "||", "<", ">", "<=", ">=", "==", "!=":
+		g.helpers["Bool"] = true
+		return fmt.Sprintf("%sBool(%s)", g.prefix, g.goBool(e))
+	}
+	x, y := g.goExpr(e.args[0]), g.goExpr(e.args[1])
+	if d := e.args[1]; (e.op == "/" || e.op == "%") && (d.op != "" || d.val == 0) {
+		name := "Div"
+		if e.op == "%" {
+			name = "Mod"
+		}
+		g.helpers[name] = true
+		return fmt.Sprintf("%s%s(%s, %s, &ok)", g.prefix, name, x, y)
+	}
+	return fmt.Sprintf("(%s %s %s)", x, e.op, y)
+}
+
+// goBool returns a Go boolean expression that is true if e is not zero.
+func (g *goWriter) goBool(e *pluralNode) string {
+	switch e.op {
+	case "!":
+		switch e.args[0].op {
+		case "!", "&&", "||", "<", ">", "<=", ">=", "==", "!=":
+			return fmt.Sprintf("!(%s)", g.goBool(e.args[0]))
+		}
+		return fmt.Sprintf("%s == 0", g.goExpr(e.args[0]))
+	case "&&", "||":
+		return fmt.Sprintf("(%s %s %s)", g.goBool(e.args[0]), e.op, g.goBool(e.args[1]))
+	case "<", ">", "<=", ">=", "==", "!=":
+		return fmt.Sprintf("%s %s %s", g.goExpr(e.args[0]), e.op, g.goExpr(e.args[1]))
+	}
+	return fmt.Sprintf("%s != 0", g.goExpr(e))
+}
+
+// goMessages sorts messages in the order of the generated table.
+type goMessages []*Message
+
+func (m goMessages) Len() int           { return len(m) }
+func (m goMessages) Less(i, j int) bool { return goCompare(m[i], m[j]) < 0 }
+func (m goMessages) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+
+// goCompare compares messages by context, messages without context first,
+// and msgid.
+func goCompare(a, b *Message) int {
+	switch {
+	case (a.Ctxt == nil) != (b.Ctxt == nil):
+		if a.Ctxt == nil {
+			return -1
+		}
+		return 1
+	case string(a.Ctxt) < string(b.Ctxt):
+		return -1
+	case string(a.Ctxt) > string(b.Ctxt):
+		return 1
+	case string(a.Id) < string(b.Id):
+		return -1
+	case string(a.Id) > string(b.Id):
+		return 1
+	}
+	return 0
+}
+
+// goPrefix returns the prefix of the unexported identifiers generated for a
+// catalog variable.
+func goPrefix(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
+
+// isGoIdentifier reports whether s is a valid Go identifier.
+func isGoIdentifier(s string) bool {
+	if s == "" || s == "_" || token.Lookup(s).IsKeyword() {
+		return false
+	}
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
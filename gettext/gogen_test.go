@@ -0,0 +1,84 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// trickyPoData has a plural rule that divides by zero, and a conditional
+// operator inside an expression.
+var trickyPoData = `msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=ISO-8859-1\n"
+"Plural-Forms: nplurals=4; plural=!n ? 3 : n%10==1 && n%100!=11 ? 0 : (n/(n%7) > 2) + (n%3 ? 1 : 0);\n"
+
+msgid "Caf\xe9"
+msgstr "Caf\xe9!"
+
+msgid "%d apple"
+msgid_plural "%d apples"
+msgstr[0] "%d apple (0)"
+msgstr[1] ""
+msgstr[2] "%d apples (2)"
+msgstr[3] "%d apples (3)"
+
+#, fuzzy
+msgid "Fuzzy"
+msgstr "Translated"
+
+msgid "Untranslated"
+msgstr ""
+
+#~ msgid "Obsolete"
+#~ msgstr "Translated"
+`
+
+// TestWriteGo checks that the generated catalogs of the gentest package
+// are up to date. The package tests the generated code.
+func TestWriteGo(t *testing.T) {
+	tests := []struct {
+		src, name, file string
+	}{
+		{polishPoData, "Polish", "internal/gentest/polish.go"},
+		{trickyPoData, "tricky", "internal/gentest/tricky.go"},
+	}
+	for _, test := range tests {
+		b := new(bytes.Buffer)
+		err := WriteGo(b, ReadPo(strings.NewReader(test.src)), &GoOptions{Package: "gentest", Name: test.name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := ioutil.ReadFile(test.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != string(expected) {
+			t.Errorf("%s is not up to date:\n%s", test.file, b)
+		}
+	}
+}
+
+func TestWriteGoErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		opt *GoOptions
+	}{
+		{polishPoData, nil},
+		{polishPoData, &GoOptions{Package: "gentest"}},
+		{polishPoData, &GoOptions{Package: "func", Name: "Polish"}},
+		{polishPoData, &GoOptions{Package: "gentest", Name: "1x"}},
+		{"msgid \"\"\nmsgstr \"Plural-Forms: nplurals=1; plural=n+;\\n\"\n", &GoOptions{Package: "gentest", Name: "Polish"}},
+		{"msgid \"a\"\nmsgstr \"b\"\n\nmsgid \"a\"\nmsgstr \"c\"\n", &GoOptions{Package: "gentest", Name: "Polish"}},
+	}
+	for _, test := range tests {
+		if err := WriteGo(ioutil.Discard, ReadPo(strings.NewReader(test.src)), test.opt); err == nil {
+			t.Errorf("%+v: expected an error.", test.opt)
+		}
+	}
+}
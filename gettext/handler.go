@@ -0,0 +1,189 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.7
+// +build go1.7
+
+package gettext
+
+import (
+	"context"
+	htmltemplate "html/template"
+	"net/http"
+	"strings"
+	texttemplate "text/template"
+)
+
+// LocaleHandler selects the locale of HTTP requests among the locales of a
+// bundle, and stores it in the request context along with its catalog and
+// a translator, for the wrapped handler to use with LocaleFromContext,
+// CatalogFromContext and TranslatorFromContext.
+//
+// The locale is taken from the first of these that names an available
+// locale:
+//
+//   - the first segment of the URL path, such as "/pt-BR/about", if
+//     PathPrefix is set. The segment is removed from the path given to the
+//     wrapped handler;
+//   - the query parameter named by QueryParam, if set;
+//   - the cookie named by Cookie, if set;
+//   - the Accept-Language header, as done by ParseAcceptLanguage and
+//     Negotiate;
+//   - DefaultLocale.
+//
+// The translator looks up messages in the selected locale, its parents and
+// DefaultLocale.
+type LocaleHandler struct {
+	Bundle        *Bundle      // catalogs to select from
+	Domain        string       // domain of the catalogs, or "" for the default one
+	DefaultLocale string       // locale used when none is requested
+	PathPrefix    bool         // read the locale from the first path segment
+	QueryParam    string       // query parameter that selects the locale, or ""
+	Cookie        string       // cookie that selects the locale, or ""
+	Handler       http.Handler // wrapped handler
+}
+
+// NewLocaleHandler returns a handler that selects the locale of requests
+// among the locales of b using the Accept-Language header, and calls h.
+// Other sources of the locale can be set in the returned handler.
+func NewLocaleHandler(b *Bundle, defaultLocale string, h http.Handler) *LocaleHandler {
+	return &LocaleHandler{
+		Bundle:        b,
+		DefaultLocale: defaultLocale,
+		Handler:       h,
+	}
+}
+
+// ServeHTTP selects the locale of the request and calls the wrapped
+// handler.
+func (h *LocaleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	available := h.locales()
+	locale, ok := "", false
+	if h.PathPrefix {
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		segment := path
+		if idx := strings.Index(path, "/"); idx != -1 {
+			segment = path[:idx]
+		}
+		if locale, ok = matchLocale(segment, available); ok {
+			u := *r.URL
+			u.Path = path[len(segment):]
+			if u.Path == "" {
+				u.Path = "/"
+			}
+			u.RawPath = ""
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = &u
+			r = r2
+		}
+	}
+	if !ok && h.QueryParam != "" {
+		locale, ok = matchLocale(r.URL.Query().Get(h.QueryParam), available)
+	}
+	if !ok && h.Cookie != "" {
+		if c, err := r.Cookie(h.Cookie); err == nil {
+			locale, ok = matchLocale(c.Value, available)
+		}
+		w.Header().Add("Vary", "Cookie")
+	}
+	if !ok {
+		requested := ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+		locale, ok = Negotiate(requested, available)
+		w.Header().Add("Vary", "Accept-Language")
+	}
+	if !ok {
+		locale = h.DefaultLocale
+	}
+	v := &localeValue{
+		locale:     canonicalLocale(locale),
+		catalog:    h.Bundle.Catalog(locale, h.Domain),
+		translator: h.Bundle.Translator(h.Domain, locale, h.DefaultLocale),
+	}
+	h.Handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), localeKey, v)))
+}
+
+// locales returns the locales that have a catalog for the domain.
+func (h *LocaleHandler) locales() []string {
+	var locales []string
+	for _, locale := range h.Bundle.Locales() {
+		if h.Bundle.Catalog(locale, h.Domain) != nil {
+			locales = append(locales, locale)
+		}
+	}
+	return locales
+}
+
+// matchLocale returns the available locale equal to the given one, once
+// canonicalized.
+func matchLocale(locale string, available []string) (string, bool) {
+	if locale == "" {
+		return "", false
+	}
+	locale = canonicalLocale(locale)
+	for _, l := range available {
+		if l == locale {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// ----------------------------------------------------------------------------
+
+// localeContextKey is the type of the context keys of this package.
+type localeContextKey int
+
+// localeKey is the context key of the locale selected by LocaleHandler.
+const localeKey localeContextKey = 0
+
+// localeValue is the context value stored by LocaleHandler.
+type localeValue struct {
+	locale     string
+	catalog    *Catalog
+	translator *Translator
+}
+
+// LocaleFromContext returns the locale selected by LocaleHandler, in
+// canonical form, and whether it is set.
+func LocaleFromContext(ctx context.Context) (string, bool) {
+	v, ok := ctx.Value(localeKey).(*localeValue)
+	if !ok {
+		return "", false
+	}
+	return v.locale, true
+}
+
+// CatalogFromContext returns the catalog of the locale selected by
+// LocaleHandler, or nil if there is none.
+func CatalogFromContext(ctx context.Context) *Catalog {
+	if v, ok := ctx.Value(localeKey).(*localeValue); ok {
+		return v.catalog
+	}
+	return nil
+}
+
+// TranslatorFromContext returns the translator of the locale selected by
+// LocaleHandler. If there is none, an empty translator is returned, whose
+// lookups return the untranslated strings.
+func TranslatorFromContext(ctx context.Context) *Translator {
+	if v, ok := ctx.Value(localeKey).(*localeValue); ok {
+		return v.translator
+	}
+	return NewTranslator()
+}
+
+// TextFuncMapFromContext returns the text/template functions of
+// TextFuncMap for the translator of the locale selected by LocaleHandler.
+// Add them to a clone of the template for each request.
+func TextFuncMapFromContext(ctx context.Context) texttemplate.FuncMap {
+	return TextFuncMap(TranslatorFromContext(ctx))
+}
+
+// HTMLFuncMapFromContext returns the html/template functions of
+// HTMLFuncMap for the translator of the locale selected by LocaleHandler.
+// Add them to a clone of the template for each request.
+func HTMLFuncMapFromContext(ctx context.Context) htmltemplate.FuncMap {
+	return HTMLFuncMap(TranslatorFromContext(ctx))
+}
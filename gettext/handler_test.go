@@ -0,0 +1,87 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.7
+// +build go1.7
+
+package gettext
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLocaleHandler(t *testing.T) {
+	b := NewBundle("")
+	for locale, data := range map[string]string{"pt_BR": brazilianPoData, "pt": portuguesePoData, "pl": polishPoData} {
+		c := NewCatalog()
+		if err := c.ReadPo(strings.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+		b.AddCatalog(locale, "", c)
+	}
+	h := NewLocaleHandler(b, "pl", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale, _ := LocaleFromContext(r.Context())
+		tr := TranslatorFromContext(r.Context())
+		fmt.Fprintf(w, "%s %s %s %s %v", r.URL.Path, locale, tr.Singular("Train"), tr.Singular("Open"), CatalogFromContext(r.Context()) != nil)
+	}))
+	h.PathPrefix = true
+	h.QueryParam = "lang"
+	h.Cookie = "lang"
+	tests := []struct {
+		url, cookie, acceptLanguage string
+		expected                    string
+	}{
+		{"/", "", "", "/ pl Train Otwórz true"},
+		{"/about", "", "pt-BR, pl;q=0.5", "/about pt-BR Comboio Otwórz true"},
+		{"/about", "", "pt-PT", "/about pt Comboio Otwórz true"},
+		{"/about", "", "de", "/about pl Train Otwórz true"},
+		{"/about", "pt", "pl", "/about pt Comboio Otwórz true"},
+		{"/about", "de", "pt", "/about pt Comboio Otwórz true"},
+		{"/about?lang=pt_BR", "pl", "pl", "/about pt-BR Comboio Otwórz true"},
+		{"/pt-br/about?lang=pl", "pl", "pl", "/about pt-BR Comboio Otwórz true"},
+		{"/pt", "", "", "/ pt Comboio Otwórz true"},
+		{"/de/about", "", "", "/de/about pl Train Otwórz true"},
+	}
+	for _, test := range tests {
+		r, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: "lang", Value: test.cookie})
+		}
+		if test.acceptLanguage != "" {
+			r.Header.Set("Accept-Language", test.acceptLanguage)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got := w.Body.String(); got != test.expected {
+			t.Errorf("%s: expected %q, got %q.", test.url, test.expected, got)
+		}
+	}
+
+	// Without a locale handler, lookups return the untranslated strings.
+	r, _ := http.NewRequest("GET", "/", nil)
+	if _, ok := LocaleFromContext(r.Context()); ok || CatalogFromContext(r.Context()) != nil {
+		t.Errorf("Expected no locale.")
+	}
+	if s := TranslatorFromContext(r.Context()).Singular("Train"); s != "Train" {
+		t.Errorf("Expected %q, got %q.", "Train", s)
+	}
+	r.Header.Set("Accept-Language", "pt")
+	h.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tmpl := template.Must(template.New("").Funcs(HTMLFuncMapFromContext(r.Context())).Parse(`{{T "Train"}}`))
+		tmpl.Execute(w, nil)
+	})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if s := w.Body.String(); s != "Comboio" {
+		t.Errorf("Expected %q, got %q.", "Comboio", s)
+	}
+}
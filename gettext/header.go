@@ -0,0 +1,189 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HeaderDateFormat is the layout of dates in catalog headers, as used by
+// time.Format.
+const HeaderDateFormat = "2006-01-02 15:04-0700"
+
+// headerKeys lists the standard header fields in the order used by GNU
+// gettext, with their usual spelling.
+var headerKeys = []string{
+	"Project-Id-Version",
+	"Report-Msgid-Bugs-To",
+	"POT-Creation-Date",
+	"PO-Revision-Date",
+	"Last-Translator",
+	"Language-Team",
+	"Language",
+	"MIME-Version",
+	"Content-Type",
+	"Content-Transfer-Encoding",
+	"Plural-Forms",
+}
+
+// CatalogHeader provides typed access to the fields of a catalog header,
+// the translation of the empty msgid. A catalog header can be converted to
+// this type to use its accessors:
+//
+//	lang := gettext.CatalogHeader(catalog.Header).Language()
+//
+// Missing fields are returned as empty strings or zero dates.
+type CatalogHeader textproto.MIMEHeader
+
+// Get returns the first value associated with the given field.
+func (h CatalogHeader) Get(key string) string {
+	return textproto.MIMEHeader(h).Get(key)
+}
+
+// Set sets the field to the given value, replacing any existing values.
+func (h CatalogHeader) Set(key, value string) {
+	textproto.MIMEHeader(h).Set(key, value)
+}
+
+// Bytes returns the header as stored in the translation of the empty
+// msgid. Standard fields are written in the order used by GNU gettext.
+func (h CatalogHeader) Bytes() []byte {
+	return headerToBytes(textproto.MIMEHeader(h))
+}
+
+// ProjectIdVersion returns the Project-Id-Version field: the name and
+// version of the translated package.
+func (h CatalogHeader) ProjectIdVersion() string {
+	return h.Get("Project-Id-Version")
+}
+
+// ReportMsgidBugsTo returns the Report-Msgid-Bugs-To field: where to report
+// bugs in the untranslated strings.
+func (h CatalogHeader) ReportMsgidBugsTo() string {
+	return h.Get("Report-Msgid-Bugs-To")
+}
+
+// POTCreationDate returns the POT-Creation-Date field: when the template
+// was extracted from the sources.
+func (h CatalogHeader) POTCreationDate() time.Time {
+	return h.date("POT-Creation-Date")
+}
+
+// PORevisionDate returns the PO-Revision-Date field: when the translation
+// was last modified.
+func (h CatalogHeader) PORevisionDate() time.Time {
+	return h.date("PO-Revision-Date")
+}
+
+// SetPORevisionDate sets the PO-Revision-Date field.
+func (h CatalogHeader) SetPORevisionDate(t time.Time) {
+	h.Set("PO-Revision-Date", t.Format(HeaderDateFormat))
+}
+
+// LastTranslator returns the Last-Translator field: the name and email
+// address of the last translator.
+func (h CatalogHeader) LastTranslator() string {
+	return h.Get("Last-Translator")
+}
+
+// LanguageTeam returns the Language-Team field.
+func (h CatalogHeader) LanguageTeam() string {
+	return h.Get("Language-Team")
+}
+
+// Language returns the Language field: the language code of the
+// translation, such as "pt_BR".
+func (h CatalogHeader) Language() string {
+	return h.Get("Language")
+}
+
+// Charset returns the charset parameter of the Content-Type field, such as
+// "UTF-8".
+func (h CatalogHeader) Charset() string {
+	_, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return params["charset"]
+}
+
+// SetCharset sets the Content-Type field to plain text in the given
+// charset.
+func (h CatalogHeader) SetCharset(charset string) {
+	h.Set("Content-Type", mime.FormatMediaType("text/plain", map[string]string{"charset": charset}))
+}
+
+// PluralForms returns the Plural-Forms field. Use ParsePluralForms to
+// compile it.
+func (h CatalogHeader) PluralForms() string {
+	return h.Get("Plural-Forms")
+}
+
+// date returns the value of a date field, or a zero time if it is missing
+// or invalid.
+func (h CatalogHeader) date(key string) time.Time {
+	t, err := time.Parse(HeaderDateFormat, h.Get(key))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// ----------------------------------------------------------------------------
+
+// bytesToHeader converts the provided bytes into a translations header.
+func bytesToHeader(header []byte) textproto.MIMEHeader {
+	reader := bufio.NewReader(bytes.NewReader(header))
+	h, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if err == io.EOF {
+		return h
+	}
+	return textproto.MIMEHeader{}
+}
+
+// headerToBytes converts the provided translations header into bytes.
+//
+// Standard fields are written first, in the order used by GNU gettext,
+// followed by other fields sorted by key. Line breaks in keys and values
+// are replaced by spaces, as they would end the field.
+func headerToBytes(header textproto.MIMEHeader) []byte {
+	if header == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	written := map[string]bool{}
+	b := new(bytes.Buffer)
+	write := func(key, name string) {
+		for _, value := range header[key] {
+			b.WriteString(headerEscaper.Replace(name))
+			b.WriteString(": ")
+			b.WriteString(headerEscaper.Replace(value))
+			b.WriteString("\n")
+		}
+		written[key] = true
+	}
+	for _, name := range headerKeys {
+		write(textproto.CanonicalMIMEHeaderKey(name), name)
+	}
+	for _, key := range keys {
+		if !written[key] {
+			write(key, key)
+		}
+	}
+	return b.Bytes()
+}
+
+var headerEscaper = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ")
@@ -0,0 +1,112 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"bytes"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+var headerPoData = `msgid ""
+msgstr ""
+"X-Generator: Poedit 1.8\n"
+"Content-Type: text/plain; charset=UTF-8\n"
+"Plural-Forms: nplurals=2; plural=(n > 1);\n"
+"Language: pt_BR\n"
+"Last-Translator: Ana <ana@example.com>\n"
+"PO-Revision-Date: 2013-05-04 12:30+0200\n"
+"POT-Creation-Date: YEAR-MO-DA HO:MI+ZONE\n"
+"Project-Id-Version: app 1.0\n"
+"MIME-Version: 1.0\n"
+"X-Accelerator-Marker: &\n"
+`
+
+func TestCatalogHeader(t *testing.T) {
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(headerPoData)); err != nil {
+		t.Fatal(err)
+	}
+	h := CatalogHeader(c.Header)
+	tests := []struct {
+		value, expected string
+	}{
+		{h.ProjectIdVersion(), "app 1.0"},
+		{h.LastTranslator(), "Ana <ana@example.com>"},
+		{h.Language(), "pt_BR"},
+		{h.Charset(), "UTF-8"},
+		{h.PluralForms(), "nplurals=2; plural=(n > 1);"},
+		{h.LanguageTeam(), ""},
+	}
+	for _, test := range tests {
+		if test.value != test.expected {
+			t.Errorf("Expected %q, got %q.", test.expected, test.value)
+		}
+	}
+	date := time.Date(2013, 5, 4, 10, 30, 0, 0, time.UTC)
+	if d := h.PORevisionDate(); !d.Equal(date) {
+		t.Errorf("Expected %v, got %v.", date, d)
+	}
+	if d := h.POTCreationDate(); !d.IsZero() {
+		t.Errorf("Expected a zero date for a placeholder, got %v.", d)
+	}
+	h.SetPORevisionDate(date)
+	h.SetCharset("ISO-8859-2")
+	if v := c.Header.Get("Po-Revision-Date"); v != "2013-05-04 10:30+0000" {
+		t.Errorf("Unexpected PO-Revision-Date %q.", v)
+	}
+	if v := c.Header.Get("Content-Type"); v != "text/plain; charset=ISO-8859-2" {
+		t.Errorf("Unexpected Content-Type %q.", v)
+	}
+}
+
+func TestHeaderToBytes(t *testing.T) {
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(headerPoData)); err != nil {
+		t.Fatal(err)
+	}
+	c.Header.Set("X-Comment", "two\nlines")
+	expected := "Project-Id-Version: app 1.0\n" +
+		"POT-Creation-Date: YEAR-MO-DA HO:MI+ZONE\n" +
+		"PO-Revision-Date: 2013-05-04 12:30+0200\n" +
+		"Last-Translator: Ana <ana@example.com>\n" +
+		"Language: pt_BR\n" +
+		"MIME-Version: 1.0\n" +
+		"Content-Type: text/plain; charset=UTF-8\n" +
+		"Plural-Forms: nplurals=2; plural=(n > 1);\n" +
+		"X-Accelerator-Marker: &\n" +
+		"X-Comment: two lines\n" +
+		"X-Generator: Poedit 1.8\n"
+	if s := string(headerToBytes(c.Header)); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, s)
+	}
+	if h := bytesToHeader([]byte(expected)); len(h) != len(c.Header) {
+		t.Errorf("Expected %d fields, got %d.", len(c.Header), len(h))
+	}
+	if headerToBytes(nil) != nil || len(headerToBytes(textproto.MIMEHeader{})) != 0 {
+		t.Errorf("Expected an empty header.")
+	}
+}
+
+func TestWriteMoReproducible(t *testing.T) {
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(headerPoData)); err != nil {
+		t.Fatal(err)
+	}
+	var first []byte
+	for i := 0; i < 10; i++ {
+		b := new(bytes.Buffer)
+		if err := WriteMo(b, c.Iter()); err != nil {
+			t.Fatal(err)
+		}
+		if first == nil {
+			first = b.Bytes()
+		} else if !bytes.Equal(b.Bytes(), first) {
+			t.Fatalf("Expected the same output on every write.")
+		}
+	}
+}
@@ -0,0 +1,7 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package gentest holds catalogs generated by gettext.WriteGo, to test the
+// generated code. The gettext tests check that they are up to date.
+package gentest
@@ -0,0 +1,77 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gentest
+
+import (
+	"testing"
+
+	"github.com/gorilla/i18n/gettext"
+)
+
+var _ gettext.Localizer = Polish
+
+func TestGeneratedLookup(t *testing.T) {
+	tests := []struct {
+		got, expected string
+	}{
+		{Polish.Singular("Open"), "Otwórz"},
+		{Polish.ContextSingular("door", "Open"), "Otwarte"},
+		{Polish.ContextSingular("window", "Open"), "Open"},
+		{Polish.Singular("%d file"), "%d file"},
+		{Polish.Singular("Close"), "Close"},
+		{Polish.Singular("Save"), "Save"},
+		{Polish.Singular("Missing %d", 3), "Missing 3"},
+		{Polish.Plural("%d file", "%d files", 1, 1), "1 plik"},
+		{Polish.Plural("%d file", "%d files", 3, 3), "3 pliki"},
+		{Polish.Plural("%d file", "%d files", 5, 5), "5 plików"},
+		{Polish.Plural("%d file", "%d files", -22, -22), "-22 pliki"},
+		{Polish.ContextPlural("disk", "%d file", "%d files", 12, 12), "12 plików na dysku"},
+		{Polish.ContextPlural("box", "%d file", "%d files", 1, 1), "1 file"},
+		{Polish.Plural("Open", "Opens", 2), "Opens"},
+		{tricky.Singular("Café"), "Café!"},
+		{tricky.Singular("Fuzzy"), "Fuzzy"},
+		{tricky.Singular("Obsolete"), "Obsolete"},
+		{tricky.Plural("%d apple", "%d apples", 0, 0), "0 apples (3)"},
+		{tricky.Plural("%d apple", "%d apples", 21, 21), "21 apple (0)"},
+		// Form 1 is empty.
+		{tricky.Plural("%d apple", "%d apples", 1, 1), "1 apple (0)"},
+	}
+	for _, test := range tests {
+		if test.got != test.expected {
+			t.Errorf("Expected %q, got %q.", test.expected, test.got)
+		}
+	}
+}
+
+func TestGeneratedPluralRules(t *testing.T) {
+	tests := []struct {
+		forms string
+		index func(int) int
+	}{
+		{"nplurals=3; plural=(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);", polishPluralIndex},
+		{"nplurals=4; plural=!n ? 3 : n%10==1 && n%100!=11 ? 0 : (n/(n%7) > 2) + (n%3 ? 1 : 0);", trickyPluralIndex},
+	}
+	for _, test := range tests {
+		rule := gettext.MustParsePluralForms(test.forms)
+		for n := -300; n <= 300; n++ {
+			if got, expected := test.index(n), rule.Index(n); got != expected {
+				t.Errorf("%q: expected %d for %d, got %d.", test.forms, expected, n, got)
+			}
+		}
+	}
+}
+
+func TestGeneratedAllocs(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		Polish.Singular("Open")
+		Polish.ContextSingular("door", "Open")
+		Polish.Plural("%d file", "%d files", 5)
+		Polish.ContextPlural("disk", "%d file", "%d files", 5)
+		Polish.Singular("Missing")
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v.", allocs)
+	}
+}
@@ -0,0 +1,127 @@
+// Code generated by gettext.WriteGo. DO NOT EDIT.
+
+package gentest
+
+import "fmt"
+
+// Polish looks up the messages of a compiled gettext catalog for pl.
+var Polish polishCatalog
+
+type polishCatalog struct{}
+
+// Singular returns the translation of key, optionally formatted with args.
+func (polishCatalog) Singular(key string, args ...interface{}) string {
+	return polishFormat(polishSingular("", false, key), args)
+}
+
+// ContextSingular is like Singular, for a message with context.
+func (polishCatalog) ContextSingular(ctxt, key string, args ...interface{}) string {
+	return polishFormat(polishSingular(ctxt, true, key), args)
+}
+
+// Plural returns the plural translation of key for n, optionally formatted with args.
+func (polishCatalog) Plural(key, keyPlural string, n int, args ...interface{}) string {
+	return polishFormat(polishPlural("", false, key, keyPlural, n), args)
+}
+
+// ContextPlural is like Plural, for a message with context.
+func (polishCatalog) ContextPlural(ctxt, key, keyPlural string, n int, args ...interface{}) string {
+	return polishFormat(polishPlural(ctxt, true, key, keyPlural, n), args)
+}
+
+func polishSingular(ctxt string, hasCtxt bool, key string) string {
+	if m := polishFind(ctxt, hasCtxt, key); m != nil && !m.plural {
+		return m.str[0]
+	}
+	return key
+}
+
+func polishPlural(ctxt string, hasCtxt bool, key, keyPlural string, n int) string {
+	if m := polishFind(ctxt, hasCtxt, key); m != nil && m.plural {
+		if idx := polishPluralIndex(n); idx < len(m.str) && m.str[idx] != "" {
+			return m.str[idx]
+		}
+	}
+	if n == 1 {
+		return key
+	}
+	return keyPlural
+}
+
+func polishFormat(s string, args []interface{}) string {
+	if len(args) == 0 {
+		return s
+	}
+	return fmt.Sprintf(s, args...)
+}
+
+// polishFind returns the message with the given context and msgid, or nil.
+func polishFind(ctxt string, hasCtxt bool, id string) *polishMessage {
+	lo, hi := 0, len(polishMessages)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		m := &polishMessages[mid]
+		switch {
+		case m.hasCtxt != hasCtxt:
+			if hasCtxt {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		case m.ctxt != ctxt:
+			if m.ctxt < ctxt {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		case m.id != id:
+			if m.id < id {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		default:
+			return m
+		}
+	}
+	return nil
+}
+
+// polishPluralIndex returns the plural form for n, compiled from
+// "nplurals=3; plural=(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);".
+// Negative numbers use the form of their absolute value, as done by
+// gettext.PluralRule.Index.
+func polishPluralIndex(n int) int {
+	v := uint64(n)
+	if n < 0 {
+		v = uint64(-n)
+	}
+	var idx uint64
+	if v == 1 {
+		idx = 0
+	} else if ((v%10) >= 2 && (v%10) <= 4) && ((v%100) < 10 || (v%100) >= 20) {
+		idx = 1
+	} else {
+		idx = 2
+	}
+	if idx >= 3 {
+		return 0
+	}
+	return int(idx)
+}
+
+type polishMessage struct {
+	ctxt    string
+	hasCtxt bool
+	id      string
+	str     []string
+	plural  bool
+}
+
+// polishMessages are sorted by context, messages without context first, and msgid.
+var polishMessages = [...]polishMessage{
+	{id: "%d file", str: []string{"%d plik", "%d pliki", "%d plików"}, plural: true},
+	{id: "Open", str: []string{"Otwórz"}},
+	{ctxt: "disk", hasCtxt: true, id: "%d file", str: []string{"%d plik na dysku", "%d pliki na dysku", "%d plików na dysku"}, plural: true},
+	{ctxt: "door", hasCtxt: true, id: "Open", str: []string{"Otwarte"}},
+}
@@ -0,0 +1,146 @@
+// Code generated by gettext.WriteGo. DO NOT EDIT.
+
+package gentest
+
+import "fmt"
+
+// tricky looks up the messages of a compiled gettext catalog.
+var tricky trickyCatalog
+
+type trickyCatalog struct{}
+
+// Singular returns the translation of key, optionally formatted with args.
+func (trickyCatalog) Singular(key string, args ...interface{}) string {
+	return trickyFormat(trickySingular("", false, key), args)
+}
+
+// ContextSingular is like Singular, for a message with context.
+func (trickyCatalog) ContextSingular(ctxt, key string, args ...interface{}) string {
+	return trickyFormat(trickySingular(ctxt, true, key), args)
+}
+
+// Plural returns the plural translation of key for n, optionally formatted with args.
+func (trickyCatalog) Plural(key, keyPlural string, n int, args ...interface{}) string {
+	return trickyFormat(trickyPlural("", false, key, keyPlural, n), args)
+}
+
+// ContextPlural is like Plural, for a message with context.
+func (trickyCatalog) ContextPlural(ctxt, key, keyPlural string, n int, args ...interface{}) string {
+	return trickyFormat(trickyPlural(ctxt, true, key, keyPlural, n), args)
+}
+
+func trickySingular(ctxt string, hasCtxt bool, key string) string {
+	if m := trickyFind(ctxt, hasCtxt, key); m != nil && !m.plural {
+		return m.str[0]
+	}
+	return key
+}
+
+func trickyPlural(ctxt string, hasCtxt bool, key, keyPlural string, n int) string {
+	if m := trickyFind(ctxt, hasCtxt, key); m != nil && m.plural {
+		if idx := trickyPluralIndex(n); idx < len(m.str) && m.str[idx] != "" {
+			return m.str[idx]
+		}
+	}
+	if n == 1 {
+		return key
+	}
+	return keyPlural
+}
+
+func trickyFormat(s string, args []interface{}) string {
+	if len(args) == 0 {
+		return s
+	}
+	return fmt.Sprintf(s, args...)
+}
+
+// trickyFind returns the message with the given context and msgid, or nil.
+func trickyFind(ctxt string, hasCtxt bool, id string) *trickyMessage {
+	lo, hi := 0, len(trickyMessages)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		m := &trickyMessages[mid]
+		switch {
+		case m.hasCtxt != hasCtxt:
+			if hasCtxt {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		case m.ctxt != ctxt:
+			if m.ctxt < ctxt {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		case m.id != id:
+			if m.id < id {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		default:
+			return m
+		}
+	}
+	return nil
+}
+
+// trickyPluralIndex returns the plural form for n, compiled from
+// "nplurals=4; plural=!n ? 3 : n%10==1 && n%100!=11 ? 0 : (n/(n%7) > 2) + (n%3 ? 1 : 0);".
+// Negative numbers use the form of their absolute value, as done by
+// gettext.PluralRule.Index.
+func trickyPluralIndex(n int) int {
+	v := uint64(n)
+	if n < 0 {
+		v = uint64(-n)
+	}
+	ok := true
+	var idx uint64
+	if v == 0 {
+		idx = 3
+	} else if (v%10) == 1 && (v%100) != 11 {
+		idx = 0
+	} else {
+		idx = (trickyBool(trickyDiv(v, (v%7), &ok) > 2) + func() uint64 {
+			if (v % 3) != 0 {
+				return 1
+			}
+			return 0
+		}())
+	}
+	if !ok || idx >= 4 {
+		return 0
+	}
+	return int(idx)
+}
+
+func trickyBool(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func trickyDiv(x, y uint64, ok *bool) uint64 {
+	if y == 0 {
+		*ok = false
+		return 0
+	}
+	return x / y
+}
+
+type trickyMessage struct {
+	ctxt    string
+	hasCtxt bool
+	id      string
+	str     []string
+	plural  bool
+}
+
+// trickyMessages are sorted by context, messages without context first, and msgid.
+var trickyMessages = [...]trickyMessage{
+	{id: "%d apple", str: []string{"%d apple (0)", "", "%d apples (2)", "%d apples (3)"}, plural: true},
+	{id: "Café", str: []string{"Café!"}},
+}
@@ -0,0 +1,212 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"fmt"
+	"strings"
+)
+
+// languageAliases maps deprecated language codes to their replacements.
+var languageAliases = map[string]string{
+	"in": "id",
+	"iw": "he",
+	"ji": "yi",
+	"jw": "jv",
+	"mo": "ro",
+}
+
+// scriptModifiers maps POSIX locale modifiers that name a script to the
+// ISO 15924 script code, as used by glibc locales such as sr_RS@latin.
+var scriptModifiers = map[string]string{
+	"arabic":     "Arab",
+	"cyrillic":   "Cyrl",
+	"devanagari": "Deva",
+	"latin":      "Latn",
+}
+
+// Locale identifies a language, optionally specialized by script, region
+// and variant. Locales are comparable, and can be used as map keys.
+//
+// Fields are in canonical case: the language in lowercase, the script in
+// title case, the region in uppercase and the variants in lowercase.
+type Locale struct {
+	Language string // ISO 639 language code, such as "pt"
+	Script   string // ISO 15924 script code, such as "Latn", or ""
+	Region   string // ISO 3166 or UN M.49 region code, such as "BR", or ""
+	Variant  string // variants separated by "-", such as "valencia", or ""
+}
+
+// ParseLocale parses a BCP 47 language tag, such as "zh-Hant-TW", or a
+// POSIX locale name, such as "sr_RS@latin" or "de_DE.UTF-8".
+//
+// The locale is canonicalized: case is normalized, deprecated language
+// codes are replaced and, in POSIX names, the codeset is dropped. POSIX
+// modifiers that name a script, such as "@latin" or "@hant", set the
+// script; the "@euro" modifier is dropped, and other modifiers become the
+// variant.
+// BCP 47 extensions and private use subtags are ignored.
+func ParseLocale(s string) (Locale, error) {
+	var l Locale
+	tag, modifier := s, ""
+	if idx := strings.Index(tag, "@"); idx != -1 {
+		tag, modifier = tag[:idx], strings.ToLower(tag[idx+1:])
+		if modifier == "" || !isAlphaNum(modifier) {
+			return l, localeError(s)
+		}
+	}
+	if idx := strings.Index(tag, "."); idx != -1 {
+		tag = tag[:idx]
+	}
+	subtags := strings.Split(strings.Replace(tag, "_", "-", -1), "-")
+	if lang := subtags[0]; len(lang) < 2 || len(lang) > 8 || !isAlpha(lang) {
+		return l, localeError(s)
+	}
+	l.Language = strings.ToLower(subtags[0])
+	if alias, ok := languageAliases[l.Language]; ok {
+		l.Language = alias
+	}
+	var variants []string
+loop:
+	for _, subtag := range subtags[1:] {
+		switch {
+		case len(subtag) == 1:
+			// Extensions and private use subtags start with a singleton.
+			break loop
+		case len(subtag) == 4 && isAlpha(subtag) && l.Script == "" && l.Region == "" && variants == nil:
+			l.Script = strings.ToUpper(subtag[:1]) + strings.ToLower(subtag[1:])
+		case (len(subtag) == 2 && isAlpha(subtag) || len(subtag) == 3 && isDigit(subtag)) && l.Region == "" && variants == nil:
+			l.Region = strings.ToUpper(subtag)
+		case isVariant(subtag):
+			variants = append(variants, strings.ToLower(subtag))
+		default:
+			return l, localeError(s)
+		}
+	}
+	script, ok := scriptModifiers[modifier]
+	if !ok && modifier != "euro" && len(modifier) == 4 && isAlpha(modifier) {
+		script, ok = strings.ToUpper(modifier[:1])+modifier[1:], true
+	}
+	switch {
+	case ok && l.Script == "":
+		l.Script = script
+	case ok, modifier == "", modifier == "euro":
+	default:
+		variants = append(variants, modifier)
+	}
+	l.Variant = strings.Join(variants, "-")
+	return l, nil
+}
+
+func localeError(s string) error {
+	return fmt.Errorf("Invalid locale %q.", s)
+}
+
+// String returns the locale as a BCP 47 language tag, such as "sr-Latn-RS".
+func (l Locale) String() string {
+	s := l.Language
+	for _, subtag := range []string{l.Script, l.Region, l.Variant} {
+		if subtag != "" {
+			s += "-" + subtag
+		}
+	}
+	return s
+}
+
+// POSIX returns the locale as a POSIX locale name without codeset, such as
+// "sr_RS@latin". The script becomes a modifier; scripts without a known
+// modifier name use the lowercase script code. If the locale has both a
+// script and a variant, the variant is used as the modifier.
+func (l Locale) POSIX() string {
+	s := l.Language
+	if l.Region != "" {
+		s += "_" + l.Region
+	}
+	switch {
+	case l.Variant != "":
+		s += "@" + l.Variant
+	case l.Script != "":
+		for modifier, script := range scriptModifiers {
+			if script == l.Script {
+				return s + "@" + modifier
+			}
+		}
+		s += "@" + strings.ToLower(l.Script)
+	}
+	return s
+}
+
+// Parent returns the locale without its most specific part: the variant,
+// the region or the script, in this order. The parent of a locale with
+// only a language is the zero Locale.
+func (l Locale) Parent() Locale {
+	switch {
+	case l.Variant != "":
+		l.Variant = ""
+	case l.Region != "":
+		l.Region = ""
+	case l.Script != "":
+		l.Script = ""
+	default:
+		return Locale{}
+	}
+	return l
+}
+
+// Parents returns the ancestors of the locale, from the most specific to
+// the language alone. For example, the parents of zh-Hant-TW are zh-Hant
+// and zh.
+func (l Locale) Parents() []Locale {
+	var parents []Locale
+	for p := l.Parent(); p.Language != ""; p = p.Parent() {
+		parents = append(parents, p)
+	}
+	return parents
+}
+
+// canonicalLocale returns the canonical BCP 47 form of a locale string, or
+// the string itself if it is not a valid locale.
+func canonicalLocale(s string) string {
+	if l, err := ParseLocale(s); err == nil {
+		return l.String()
+	}
+	return s
+}
+
+// isVariant reports whether s is a BCP 47 variant subtag: 5 to 8
+// alphanumeric characters, or 4 starting with a digit.
+func isVariant(s string) bool {
+	if !isAlphaNum(s) {
+		return false
+	}
+	return len(s) >= 5 && len(s) <= 8 || len(s) == 4 && isDigit(s[:1])
+}
+
+func isAlpha(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlphaNum(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isAlpha(s[i:i+1]) && !isDigit(s[i:i+1]) {
+			return false
+		}
+	}
+	return true
+}
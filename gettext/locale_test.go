@@ -0,0 +1,90 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLocale(t *testing.T) {
+	tests := []struct {
+		src    string
+		locale Locale
+		tag    string
+		posix  string
+	}{
+		{"en", Locale{Language: "en"}, "en", "en"},
+		{"pt-BR", Locale{Language: "pt", Region: "BR"}, "pt-BR", "pt_BR"},
+		{"pt_br", Locale{Language: "pt", Region: "BR"}, "pt-BR", "pt_BR"},
+		{"zh-hant-tw", Locale{Language: "zh", Script: "Hant", Region: "TW"}, "zh-Hant-TW", "zh_TW@hant"},
+		{"sr-Latn", Locale{Language: "sr", Script: "Latn"}, "sr-Latn", "sr@latin"},
+		{"sr_RS@latin", Locale{Language: "sr", Script: "Latn", Region: "RS"}, "sr-Latn-RS", "sr_RS@latin"},
+		{"uz_UZ.UTF-8@cyrillic", Locale{Language: "uz", Script: "Cyrl", Region: "UZ"}, "uz-Cyrl-UZ", "uz_UZ@cyrillic"},
+		{"de_DE.UTF-8", Locale{Language: "de", Region: "DE"}, "de-DE", "de_DE"},
+		{"de_DE@euro", Locale{Language: "de", Region: "DE"}, "de-DE", "de_DE"},
+		{"ca_ES@valencia", Locale{Language: "ca", Region: "ES", Variant: "valencia"}, "ca-ES-valencia", "ca_ES@valencia"},
+		{"es-419", Locale{Language: "es", Region: "419"}, "es-419", "es_419"},
+		{"de-CH-1901", Locale{Language: "de", Region: "CH", Variant: "1901"}, "de-CH-1901", "de_CH@1901"},
+		{"en-US-u-ca-gregory", Locale{Language: "en", Region: "US"}, "en-US", "en_US"},
+		{"iw-IL", Locale{Language: "he", Region: "IL"}, "he-IL", "he_IL"},
+	}
+	for _, test := range tests {
+		l, err := ParseLocale(test.src)
+		if err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+		if l != test.locale {
+			t.Errorf("%q: expected %#v, got %#v.", test.src, test.locale, l)
+		}
+		if s := l.String(); s != test.tag {
+			t.Errorf("%q: expected tag %q, got %q.", test.src, test.tag, s)
+		}
+		if s := l.POSIX(); s != test.posix {
+			t.Errorf("%q: expected POSIX name %q, got %q.", test.src, test.posix, s)
+		}
+		// Both forms parse back to the same locale.
+		for _, s := range []string{test.tag, test.posix} {
+			if l2, err := ParseLocale(s); err != nil || l2 != l {
+				t.Errorf("%q: expected %#v, got %#v (%v).", s, l, l2, err)
+			}
+		}
+	}
+}
+
+func TestParseLocaleErrors(t *testing.T) {
+	for _, src := range []string{"", "e", "en-", "en--US", "en_US@", "en@a-b", "1a", "en-US-DE", "en-toolongvariant"} {
+		if _, err := ParseLocale(src); err == nil {
+			t.Errorf("%q: expected an error.", src)
+		}
+	}
+}
+
+func TestLocaleParents(t *testing.T) {
+	tests := []struct {
+		src     string
+		parents []string
+	}{
+		{"en", nil},
+		{"pt-BR", []string{"pt"}},
+		{"zh-Hant-TW", []string{"zh-Hant", "zh"}},
+		{"sr_RS@latin", []string{"sr-Latn", "sr"}},
+		{"ca-ES-valencia", []string{"ca-ES", "ca"}},
+	}
+	for _, test := range tests {
+		l, err := ParseLocale(test.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var parents []string
+		for _, p := range l.Parents() {
+			parents = append(parents, p.String())
+		}
+		if !reflect.DeepEqual(parents, test.parents) {
+			t.Errorf("%q: expected parents %v, got %v.", test.src, test.parents, parents)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"io"
+)
+
+// fuzzyThreshold is the minimum similarity of two msgids for a translation
+// to be reused as a fuzzy match, as in GNU msgmerge.
+const fuzzyThreshold = 0.6
+
+// Merge updates the translations provided by def to the messages of the
+// template ref, like GNU msgmerge does, and returns the updated catalog.
+//
+// Messages are returned in the order of the template, followed by obsolete
+// messages:
+//
+//   - Messages of the template with the same msgctxt and msgid as a
+//     message of def keep its translation and translator comments.
+//     References, extracted comments and flags come from the template.
+//   - Other messages of the template get the translation of the most
+//     similar message of def, if any. They are flagged as fuzzy, and the
+//     previous msgctxt and msgid are kept in PrevCtxt and PrevId, so that
+//     translators can review them.
+//   - Translated messages of def that are not in the template become
+//     obsolete.
+//
+// The header of def is kept, with the POT-Creation-Date of the template.
+func Merge(def, ref Iterator) (Iterator, error) {
+	defs, err := readAll(def)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := readAll(ref)
+	if err != nil {
+		return nil, err
+	}
+	m := &merger{
+		exact:   map[string]*Message{},
+		matched: map[*Message]bool{},
+		plural:  DefaultPluralRule,
+	}
+	for _, msg := range defs {
+		key := m.key(msg)
+		if current, ok := m.exact[key]; !ok || isObsolete(current) && !isObsolete(msg) {
+			m.exact[key] = msg
+		}
+		if key == "" && !isObsolete(msg) {
+			if rule, err := ParsePluralForms(bytesToHeader(msg.Str).Get("Plural-Forms")); err == nil {
+				m.plural = rule
+			}
+		}
+		if !isObsolete(msg) && key != "" && isTranslated(msg) {
+			m.candidates = append(m.candidates, newFuzzyCandidate(msg))
+		}
+	}
+	var msgs []*Message
+	for _, msg := range refs {
+		if isObsolete(msg) {
+			continue
+		}
+		msgs = append(msgs, m.merge(msg))
+	}
+	for _, msg := range defs {
+		if !m.matched[msg] && isTranslated(msg) && m.key(msg) != "" {
+			msgs = append(msgs, obsoleteMessage(msg))
+		}
+	}
+	return &messageIterator{msgs: msgs}, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// merger merges messages from a template with existing translations.
+type merger struct {
+	exact      map[string]*Message // existing messages by key
+	candidates []*fuzzyCandidate   // translated messages for fuzzy matches
+	matched    map[*Message]bool   // existing messages found in the template
+	plural     *PluralRule         // rule of the existing header
+}
+
+func (m *merger) key(msg *Message) string {
+	if msg.Ctxt == nil {
+		return string(msg.Id)
+	}
+	return contextKey(string(msg.Ctxt), string(msg.Id))
+}
+
+// merge returns the updated version of a template message.
+func (m *merger) merge(msg *Message) *Message {
+	if len(msg.Id) == 0 && msg.Ctxt == nil {
+		return m.mergeHeader(msg)
+	}
+	res := &Message{
+		Ctxt:     msg.Ctxt,
+		Id:       msg.Id,
+		IdPlural: msg.IdPlural,
+		Meta:     &MessageMeta{},
+	}
+	if meta := msg.Meta; meta != nil {
+		res.Meta.ExtractedComments = meta.ExtractedComments
+		res.Meta.References = meta.References
+		for _, flag := range meta.Flags {
+			if string(flag) != "fuzzy" {
+				res.Meta.Flags = append(res.Meta.Flags, flag)
+			}
+		}
+	}
+	if old, ok := m.exact[m.key(msg)]; ok {
+		m.matched[old] = true
+		if old.Meta != nil {
+			res.Meta.TranslatorComments = old.Meta.TranslatorComments
+		}
+		m.setTranslation(res, old)
+		fuzzy := old.HasFlag("fuzzy")
+		if fuzzy && old.Meta != nil {
+			res.Meta.PrevCtxt = old.Meta.PrevCtxt
+			res.Meta.PrevId = old.Meta.PrevId
+			res.Meta.PrevIdPlural = old.Meta.PrevIdPlural
+		}
+		if (old.IdPlural == nil) != (msg.IdPlural == nil) || string(old.IdPlural) != string(msg.IdPlural) {
+			// The plural msgid changed: the translation must be reviewed.
+			fuzzy = true
+			if res.Meta.PrevId == nil {
+				res.Meta.PrevCtxt, res.Meta.PrevId, res.Meta.PrevIdPlural = old.Ctxt, old.Id, old.IdPlural
+			}
+		}
+		if fuzzy {
+			res.Meta.Flags = append([][]byte{[]byte("fuzzy")}, res.Meta.Flags...)
+		}
+		return res
+	}
+	if old := m.fuzzyMatch(msg); old != nil {
+		m.setTranslation(res, old)
+		res.Meta.PrevCtxt, res.Meta.PrevId, res.Meta.PrevIdPlural = old.Ctxt, old.Id, old.IdPlural
+		res.Meta.Flags = append([][]byte{[]byte("fuzzy")}, res.Meta.Flags...)
+		return res
+	}
+	m.setTranslation(res, nil)
+	return res
+}
+
+// mergeHeader returns the existing header, updated with the creation date
+// of the template.
+func (m *merger) mergeHeader(msg *Message) *Message {
+	old, ok := m.exact[""]
+	if !ok || isObsolete(old) {
+		return msg
+	}
+	m.matched[old] = true
+	header := bytesToHeader(old.Str)
+	if date := bytesToHeader(msg.Str).Get("Pot-Creation-Date"); date != "" {
+		header.Set("Pot-Creation-Date", date)
+	}
+	res := *old
+	res.Str = headerToBytes(header)
+	return &res
+}
+
+// setTranslation sets the translation of msg from old, adapting it if one
+// of them is a plural message and the other is not. If old is nil, empty
+// translations are set.
+func (m *merger) setTranslation(msg, old *Message) {
+	if msg.IdPlural == nil {
+		msg.Str = []byte{}
+		if old != nil {
+			if old.IdPlural == nil {
+				msg.Str = old.Str
+			} else if len(old.StrPlural) > 0 {
+				msg.Str = old.StrPlural[0]
+			}
+		}
+		return
+	}
+	if old != nil && old.IdPlural != nil {
+		msg.StrPlural = old.StrPlural
+		return
+	}
+	msg.StrPlural = make([][]byte, m.plural.NPlurals)
+	for i := range msg.StrPlural {
+		msg.StrPlural[i] = []byte{}
+	}
+	if old != nil {
+		msg.StrPlural[0] = old.Str
+	}
+}
+
+// fuzzyMatch returns the translated message whose context and msgid are
+// the most similar to the ones of msg, or nil if none is similar enough.
+func (m *merger) fuzzyMatch(msg *Message) *Message {
+	c := newFuzzyCandidate(msg)
+	var best *Message
+	bestScore := fuzzyThreshold
+	for _, candidate := range m.candidates {
+		if score := c.similarity(candidate, bestScore); score >= bestScore {
+			if score > bestScore || best == nil {
+				best, bestScore = candidate.msg, score
+			}
+		}
+	}
+	return best
+}
+
+// ----------------------------------------------------------------------------
+
+// fuzzyCandidate is a message prepared for similarity comparisons.
+type fuzzyCandidate struct {
+	msg   *Message
+	text  []byte     // context and msgid
+	count [256]int32 // occurrences of each byte in text
+}
+
+func newFuzzyCandidate(msg *Message) *fuzzyCandidate {
+	c := &fuzzyCandidate{msg: msg}
+	if msg.Ctxt != nil {
+		c.text = append(append(c.text, msg.Ctxt...), eotBytes...)
+	}
+	c.text = append(c.text, msg.Id...)
+	for _, b := range c.text {
+		c.count[b]++
+	}
+	return c
+}
+
+// similarity returns how similar the texts of two candidates are, between
+// 0 and 1, as twice the length of their longest common subsequence divided
+// by the sum of their lengths. Cheaper upper bounds are checked first, and
+// 0 is returned if they are under min.
+func (c *fuzzyCandidate) similarity(o *fuzzyCandidate, min float64) float64 {
+	a, b := c.text, o.text
+	total := float64(len(a) + len(b))
+	if total == 0 {
+		return 1
+	}
+	short := len(a)
+	if len(b) < short {
+		short = len(b)
+	}
+	if 2*float64(short)/total < min {
+		return 0
+	}
+	common := 0
+	for i := range c.count {
+		if c.count[i] < o.count[i] {
+			common += int(c.count[i])
+		} else {
+			common += int(o.count[i])
+		}
+	}
+	if 2*float64(common)/total < min {
+		return 0
+	}
+	// Longest common subsequence, keeping a single row of the table.
+	row := make([]int, len(b)+1)
+	for i := 1; i <= len(a); i++ {
+		diag := 0
+		for j := 1; j <= len(b); j++ {
+			up := row[j]
+			if a[i-1] == b[j-1] {
+				row[j] = diag + 1
+			} else if row[j-1] > row[j] {
+				row[j] = row[j-1]
+			}
+			diag = up
+		}
+	}
+	return 2 * float64(row[len(b)]) / total
+}
+
+// ----------------------------------------------------------------------------
+
+// readAll reads all messages provided by iter.
+func readAll(iter Iterator) ([]*Message, error) {
+	var msgs []*Message
+	for {
+		msg, err := iter.Next()
+		if err == io.EOF {
+			return msgs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+// isObsolete reports whether msg is an obsolete message.
+func isObsolete(msg *Message) bool {
+	return msg.Meta != nil && msg.Meta.Obsolete
+}
+
+// isTranslated reports whether msg has a non-empty translation.
+func isTranslated(msg *Message) bool {
+	if len(msg.Str) > 0 {
+		return true
+	}
+	for _, s := range msg.StrPlural {
+		if len(s) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// obsoleteMessage returns a copy of msg marked as obsolete, without
+// references.
+func obsoleteMessage(msg *Message) *Message {
+	res := *msg
+	res.Meta = &MessageMeta{Obsolete: true}
+	if msg.Meta != nil {
+		meta := *msg.Meta
+		meta.References = nil
+		meta.Obsolete = true
+		res.Meta = &meta
+	}
+	return &res
+}
+
+// messageIterator iterates over a list of messages.
+type messageIterator struct {
+	msgs []*Message
+	pos  int
+}
+
+// Size returns the amount of messages provided by the iterator.
+func (i *messageIterator) Size() int {
+	return len(i.msgs)
+}
+
+// Next returns the next message. At the end of the iteration,
+// io.EOF is returned as the error.
+func (i *messageIterator) Next() (*Message, error) {
+	if i.pos >= len(i.msgs) {
+		return nil, io.EOF
+	}
+	i.pos += 1
+	return i.msgs[i.pos-1], nil
+}
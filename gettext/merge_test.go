@@ -0,0 +1,156 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var mergeDefData = `# Spanish translations.
+msgid ""
+msgstr ""
+"Project-Id-Version: app 1.0\n"
+"POT-Creation-Date: 2013-01-01 10:00+0000\n"
+"Language: es\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+# Keep this comment.
+#: old.go:1
+msgid "Open"
+msgstr "Abrir"
+
+msgid "Save the file as %s"
+msgstr "Guardar el fichero como %s"
+
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d fichero"
+msgstr[1] "%d ficheros"
+
+msgid "Removed"
+msgstr "Eliminado"
+
+msgid "Untranslated and removed"
+msgstr ""
+`
+
+var mergeRefData = `msgid ""
+msgstr ""
+"Project-Id-Version: PACKAGE VERSION\n"
+"POT-Creation-Date: 2013-02-01 10:00+0000\n"
+
+#: main.go:10
+msgid "Open"
+msgstr ""
+
+#: main.go:11
+#, go-format
+msgid "Save the files as %s"
+msgstr ""
+
+#: main.go:12
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] ""
+msgstr[1] ""
+
+#: main.go:13
+msgid "Brand new"
+msgid_plural "Brand new ones"
+msgstr[0] ""
+msgstr[1] ""
+`
+
+var mergeResult = `# Spanish translations.
+msgid ""
+msgstr ""
+"Project-Id-Version: app 1.0\n"
+"POT-Creation-Date: 2013-02-01 10:00+0000\n"
+"Language: es\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+# Keep this comment.
+#: main.go:10
+msgid "Open"
+msgstr "Abrir"
+
+#: main.go:11
+#, fuzzy, go-format
+#| msgid "Save the file as %s"
+msgid "Save the files as %s"
+msgstr "Guardar el fichero como %s"
+
+#: main.go:12
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d fichero"
+msgstr[1] "%d ficheros"
+
+#: main.go:13
+msgid "Brand new"
+msgid_plural "Brand new ones"
+msgstr[0] ""
+msgstr[1] ""
+
+#~ msgid "Save the file as %s"
+#~ msgstr "Guardar el fichero como %s"
+
+#~ msgid "Removed"
+#~ msgstr "Eliminado"
+`
+
+func TestMerge(t *testing.T) {
+	iter, err := Merge(ReadPo(strings.NewReader(mergeDefData)), ReadPo(strings.NewReader(mergeRefData)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := new(bytes.Buffer)
+	if err := WritePo(b, iter); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.String(); s != mergeResult {
+		t.Errorf("Expected:\n%s\nGot:\n%s", mergeResult, s)
+	}
+	// Merging again with the same template keeps the result.
+	iter, err = Merge(ReadPo(strings.NewReader(mergeResult)), ReadPo(strings.NewReader(mergeRefData)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	if err := WritePo(b, iter); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.String(); s != mergeResult {
+		t.Errorf("Expected:\n%s\nGot:\n%s", mergeResult, s)
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	if _, err := Merge(ReadPo(strings.NewReader("msgid")), ReadPo(strings.NewReader(mergeRefData))); err == nil {
+		t.Errorf("Expected a syntax error.")
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected float64
+	}{
+		{"", "", 1},
+		{"abc", "abc", 1},
+		{"abc", "xyz", 0},
+		{"Save the file", "Save the files", 26.0 / 27},
+		{"abcd", "acbd", 0.75},
+	}
+	for _, test := range tests {
+		a := newFuzzyCandidate(&Message{Id: []byte(test.a)})
+		b := newFuzzyCandidate(&Message{Id: []byte(test.b)})
+		if s := a.similarity(b, 0); s != test.expected {
+			t.Errorf("%q, %q: expected %v, got %v.", test.a, test.b, test.expected, s)
+		}
+	}
+}
@@ -11,7 +11,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"net/textproto"
+	"math"
+	"sort"
 )
 
 // Message stores a gettext message.
@@ -33,6 +34,20 @@ type MessageMeta struct {
 	PrevCtxt           []byte
 	PrevId             []byte
 	PrevIdPlural       []byte
+	Obsolete           bool // entry is commented out with "#~"
+}
+
+// HasFlag reports whether the message has the given flag, such as "fuzzy"
+// or "c-format".
+func (m *Message) HasFlag(flag string) bool {
+	if m.Meta != nil {
+		for _, f := range m.Meta.Flags {
+			if string(f) == flag {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // Iterator iterates over gettext messages.
@@ -47,20 +62,108 @@ type Iterator interface {
 // ----------------------------------------------------------------------------
 
 // ReadMo reads a MO file from r and returns a messages iterator.
+//
+// The file is read from the current position of r to its end. Invalid
+// files are reported by the iterator with a *MoFormatError.
 func ReadMo(r io.ReadSeeker) Iterator {
 	return &moReader{reader: r}
 }
 
+// ReadMoOptions is like ReadMo, but uses the provided options to limit the
+// size of the file. If opt is nil, the default options are used.
+func ReadMoOptions(r io.ReadSeeker, opt *MoOptions) Iterator {
+	if opt == nil {
+		opt = &MoOptions{}
+	}
+	return &moReader{reader: r, maxSize: opt.MaxSize}
+}
+
+// MoFormatError is returned when a MO file is invalid or exceeds the
+// configured size limit.
+type MoFormatError struct {
+	Offset int64  // offset in the file where the error was found
+	Index  int    // index of the message being read, or -1
+	Msg    string // description of the error
+}
+
+func (e *MoFormatError) Error() string {
+	if e.Index >= 0 {
+		return fmt.Sprintf("Invalid MO file at offset %d, message %d: %s.", e.Offset, e.Index, e.Msg)
+	}
+	return fmt.Sprintf("Invalid MO file at offset %d: %s.", e.Offset, e.Msg)
+}
+
+// LookupMo reads a single message from a MO file, given its context and
+// msgid, without iterating over all messages in the file. The context must
+// be nil for messages without context.
+//
+// The hash table of the file is used if present; otherwise the message is
+// found by binary search, which requires msgids to be sorted as done by
+// msgfmt and WriteMo. If the message is not found, a nil message is
+// returned with no error.
+func LookupMo(r io.ReadSeeker, ctxt, id []byte) (*Message, error) {
+	key := id
+	if ctxt != nil {
+		key = append(append(append([]byte{}, ctxt...), eotBytes...), id...)
+	}
+	reader := &moReader{reader: r}
+	return reader.find(key)
+}
+
 // WriteMo writes a MO file to w using the provided messages iterator.
-func WriteMo(w io.WriteSeeker, iter Iterator) error {
+//
+// The file is written in a single forward pass, so w can be any stream,
+// such as an HTTP response or a compressed file. To compute the layout of
+// the file, all messages are read from the iterator before writing.
+func WriteMo(w io.Writer, iter Iterator) error {
+	return WriteMoOptions(w, iter, nil)
+}
+
+// MoOptions configures how MO files are read and written. The zero value
+// writes the same files as GNU msgfmt with the default options.
+type MoOptions struct {
+	// ByteOrder is the byte order of the file: binary.LittleEndian or
+	// binary.BigEndian. If nil, little endian is used.
+	ByteOrder binary.ByteOrder
+	// MajorRevision and MinorRevision are the file format revision.
+	// Only major revisions 0 and 1 are supported.
+	MajorRevision, MinorRevision uint16
+	// NoHashTable omits the hash table used to speed up lookups, like
+	// msgfmt --no-hash.
+	NoHashTable bool
+	// MaxSize is the maximum size in bytes of files read with
+	// ReadMoOptions. If zero, files are only limited by the size of the
+	// stream. It is ignored when writing.
+	MaxSize int64
+}
+
+// WriteMoOptions writes a MO file to w using the provided messages iterator
+// and options. If opt is nil, the default options are used.
+func WriteMoOptions(w io.Writer, iter Iterator, opt *MoOptions) error {
+	if opt == nil {
+		opt = &MoOptions{}
+	}
 	writer := &moWriter{
-		writer: w,
-		iter:   iter,
+		writer:   bufio.NewWriter(w),
+		iter:     iter,
+		order:    opt.ByteOrder,
+		revision: moRevision{opt.MajorRevision, opt.MinorRevision},
+		noHash:   opt.NoHashTable,
+	}
+	switch writer.order {
+	case nil:
+		writer.order = binary.LittleEndian
+	case binary.LittleEndian, binary.BigEndian:
+	default:
+		return errors.New("Unsupported byte order.")
+	}
+	if writer.revision.Major > 1 {
+		return errors.New("Unsupported major revision number.")
 	}
 	if err := writer.writeAll(); err != nil {
 		return err
 	}
-	return nil
+	return writer.writer.Flush()
 }
 
 // ----------------------------------------------------------------------------
@@ -81,22 +184,38 @@ type moRevision struct {
 	Major, Minor uint16
 }
 
+// value returns the revision as stored in the file: the major revision
+// number is stored in the high 16 bits.
+func (r moRevision) value() uint32 {
+	return uint32(r.Major)<<16 | uint32(r.Minor)
+}
+
 type moHeader struct {
 	MsgCount, IdTableOffset, StrTableOffset, HashSize, HashOffset uint32
 }
 
+// moSysdepHeader holds the header fields of MO files with minor revision 1
+// or greater, which describe system-dependent strings.
+type moSysdepHeader struct {
+	SegmentCount, SegmentOffset, StringCount, IdTableOffset, StrTableOffset uint32
+}
+
 type moPosition struct {
 	Size, Offset uint32
 }
 
 // moReader reads a MO file.
 type moReader struct {
-	reader io.ReadSeeker    // stream reader
-	order  binary.ByteOrder // byte order of the stream
-	offset int64            // relative offset of the stream
-	header *moHeader        // parsed catalog header
-	pos    uint32           // iterator position
-	err    error            // iterator error
+	reader   io.ReadSeeker    // stream reader
+	order    binary.ByteOrder // byte order of the stream
+	offset   int64            // relative offset of the stream
+	size     int64            // size of the stream from its initial offset
+	maxSize  int64            // maximum size of the stream, or 0
+	header   *moHeader        // parsed catalog header
+	sysdep   moSysdepHeader   // system-dependent strings header
+	segments []string         // expanded system-dependent segments
+	pos      uint32           // iterator position
+	err      error            // iterator error
 }
 
 func (r *moReader) init() {
@@ -104,6 +223,7 @@ func (r *moReader) init() {
 		h, err := r.readHeader()
 		if err != nil {
 			h = moHeader{}
+			r.sysdep = moSysdepHeader{}
 			r.err = err
 		}
 		r.header = &h
@@ -113,7 +233,7 @@ func (r *moReader) init() {
 // Size returns the amount of messages provided by the iterator.
 func (r *moReader) Size() int {
 	r.init()
-	return int(r.header.MsgCount)
+	return int(r.header.MsgCount) + int(r.sysdep.StringCount)
 }
 
 // Next returns the next message. At the end of the iteration,
@@ -123,83 +243,313 @@ func (r *moReader) Next() (*Message, error) {
 	if r.err != nil {
 		return nil, r.err
 	}
-	if r.pos >= r.header.MsgCount {
+	var msg *Message
+	var err error
+	switch {
+	case r.pos < r.header.MsgCount:
+		msg, err = r.readEntry(r.pos)
+	case r.pos-r.header.MsgCount < r.sysdep.StringCount:
+		// System-dependent strings come after the regular ones.
+		msg, err = r.readSysdepEntry(r.pos - r.header.MsgCount)
+	default:
 		r.err = io.EOF
 		return nil, r.err
 	}
-	msg := Message{}
-	var err error
-	// Read msgid and msgstr.
-	if msg.Id, err = r.readMessage(r.header.IdTableOffset + r.pos*8); err != nil {
+	if err != nil {
 		r.err = err
 		return nil, err
 	}
-	if msg.Str, err = r.readMessage(r.header.StrTableOffset + r.pos*8); err != nil {
-		r.err = err
+	r.pos += 1
+	return msg, nil
+}
+
+// find returns the message with the given msgid, including the context if
+// any. A nil message is returned if the msgid is not found.
+func (r *moReader) find(key []byte) (*Message, error) {
+	msg, err := r.findStatic(key)
+	if msg != nil || err != nil {
+		return msg, err
+	}
+	// System-dependent strings are few; just check them all.
+	for i := uint32(0); i < r.sysdep.StringCount; i++ {
+		id, err := r.readSysdepString(r.sysdep.IdTableOffset + i*4)
+		if err != nil {
+			return nil, withIndex(err, int(r.header.MsgCount+i))
+		}
+		if bytes.Equal(msgidKey(id), key) {
+			return r.readSysdepEntry(i)
+		}
+	}
+	return nil, nil
+}
+
+// findStatic returns the regular message with the given msgid. It uses the
+// hash table if the file has one, or a binary search on the sorted msgid
+// table otherwise.
+//
+// Hash table entries greater than MsgCount refer to system-dependent
+// messages, stored at MsgCount+1+j for the jth one, as done by GNU libintl.
+// They are skipped: only an empty slot ends the probe.
+func (r *moReader) findStatic(key []byte) (*Message, error) {
+	r.init()
+	if r.err != nil {
+		return nil, r.err
+	}
+	h := r.header
+	if h.HashSize > 2 {
+		hash := hashString(key)
+		idx := hash % h.HashSize
+		incr := 1 + hash%(h.HashSize-2)
+		for i := uint32(0); i < h.HashSize; i++ {
+			n, err := r.readUint32(h.HashOffset + idx*4)
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				return nil, nil
+			}
+			if n <= h.MsgCount {
+				id, err := r.readMessage(h.IdTableOffset + (n-1)*8)
+				if err != nil {
+					return nil, withIndex(err, int(n-1))
+				}
+				if bytes.Equal(msgidKey(id), key) {
+					return r.readEntry(n - 1)
+				}
+			}
+			if idx >= h.HashSize-incr {
+				idx -= h.HashSize - incr
+			} else {
+				idx += incr
+			}
+		}
+		return nil, nil
+	}
+	lo, hi := uint32(0), h.MsgCount
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		id, err := r.readMessage(h.IdTableOffset + mid*8)
+		if err != nil {
+			return nil, withIndex(err, int(mid))
+		}
+		switch bytes.Compare(msgidKey(id), key) {
+		case 0:
+			return r.readEntry(mid)
+		case -1:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return nil, nil
+}
+
+// readEntry reads the message at the given index of the tables.
+func (r *moReader) readEntry(idx uint32) (*Message, error) {
+	// Read msgid and msgstr.
+	id, err := r.readMessage(r.header.IdTableOffset + idx*8)
+	if err != nil {
+		return nil, withIndex(err, int(idx))
+	}
+	str, err := r.readMessage(r.header.StrTableOffset + idx*8)
+	if err != nil {
+		return nil, withIndex(err, int(idx))
+	}
+	return decodeMessage(id, str), nil
+}
+
+// readSysdepEntry reads the system-dependent message at the given index of
+// the tables, expanding its segments.
+func (r *moReader) readSysdepEntry(idx uint32) (*Message, error) {
+	id, err := r.readSysdepString(r.sysdep.IdTableOffset + idx*4)
+	if err != nil {
+		return nil, withIndex(err, int(r.header.MsgCount+idx))
+	}
+	str, err := r.readSysdepString(r.sysdep.StrTableOffset + idx*4)
+	if err != nil {
+		return nil, withIndex(err, int(r.header.MsgCount+idx))
+	}
+	return decodeMessage(id, str), nil
+}
+
+// readSysdepString reads a system-dependent string given the offset of its
+// table entry, and expands its segments.
+func (r *moReader) readSysdepString(tableOffset uint32) ([]byte, error) {
+	if r.segments == nil {
+		if err := r.readSegments(); err != nil {
+			return nil, err
+		}
+	}
+	offset, err := r.readUint32(tableOffset)
+	if err != nil {
 		return nil, err
 	}
-	// Is this a context message?
-	if idx := bytes.Index(msg.Id, eotBytes); idx != -1 {
-		msg.Ctxt = msg.Id[:idx]
-		msg.Id = msg.Id[idx+1:]
+	// The sysdep_string structure: offset of the static parts, followed
+	// by pairs of static part size and segment index.
+	static, err := r.readUint32(offset)
+	if err != nil {
+		return nil, err
 	}
-	// Is this a plural message?
-	if idx := bytes.Index(msg.Id, nulBytes); idx != -1 {
-		msg.IdPlural = msg.Id[idx+1:]
-		msg.Id = msg.Id[:idx]
-		msg.StrPlural = bytes.Split(msg.Str, nulBytes)
-		msg.Str = nil
+	var b []byte
+	for pos := int64(offset) + 4; ; pos += 8 {
+		if pos+8 > r.size {
+			return nil, r.errorf(int64(offset), "unterminated system-dependent string")
+		}
+		size, err := r.readUint32(uint32(pos))
+		if err != nil {
+			return nil, err
+		}
+		segment, err := r.readUint32(uint32(pos + 4))
+		if err != nil {
+			return nil, err
+		}
+		part, err := r.readBytes(static, size)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, part...)
+		static += size
+		if segment == moSegmentsEnd {
+			break
+		}
+		if segment >= uint32(len(r.segments)) {
+			return nil, r.errorf(pos+4, "invalid system-dependent segment %d", segment)
+		}
+		b = append(b, r.segments[segment]...)
 	}
-	r.pos += 1
-	return &msg, nil
+	// The last static part includes the NUL char.
+	if len(b) > 0 && b[len(b)-1] == 0 {
+		b = b[:len(b)-1]
+	}
+	return b, nil
+}
+
+// readSegments reads and expands the system-dependent segments.
+func (r *moReader) readSegments() error {
+	r.segments = make([]string, 0, r.sysdep.SegmentCount)
+	for i := uint32(0); i < r.sysdep.SegmentCount; i++ {
+		name, err := r.readMessage(r.sysdep.SegmentOffset + i*8)
+		if err != nil {
+			return err
+		}
+		value, ok := sysdepSegmentValue(string(bytes.TrimRight(name, "\x00")))
+		if !ok {
+			return r.errorf(int64(r.sysdep.SegmentOffset+i*8), "unknown system-dependent segment %q", name)
+		}
+		r.segments = append(r.segments, value)
+	}
+	return nil
 }
 
-// readHeader reads the MO file header.
+// readHeader reads the MO file header, and checks that the tables it
+// describes are inside the stream.
 func (r *moReader) readHeader() (moHeader, error) {
 	h := moHeader{}
-	if err := r.seek(0); err != nil {
+	if err := r.readSize(); err != nil {
 		return h, err
 	}
+	if r.maxSize > 0 && r.size > r.maxSize {
+		return h, r.errorf(0, "file size of %d bytes exceeds the limit of %d bytes", r.size, r.maxSize)
+	}
+	if r.size < 28 {
+		return h, r.errorf(0, "file is too short")
+	}
 	// byte 0: byte order.
-	var order uint32
-	if err := binary.Read(r.reader, binary.LittleEndian, &order); err != nil {
+	r.order = binary.LittleEndian
+	order, err := r.readUint32(0)
+	if err != nil {
 		return h, err
 	}
 	switch order {
 	case bigEndian:
 		r.order = binary.BigEndian
 	case littleEndian:
-		r.order = binary.LittleEndian
 	default:
-		return h, errors.New("Unable to identify the byte order.")
+		return h, r.errorf(0, "unable to identify the byte order")
 	}
-	// byte 4: major revision number.
-	// byte 6: minor revision number (ignored).
-	revision := moRevision{}
-	if err := binary.Read(r.reader, r.order, &revision); err != nil {
+	// byte 4: revision number; major revision in the high 16 bits and
+	// minor revision (ignored) in the low 16 bits.
+	value, err := r.readUint32(4)
+	if err != nil {
 		return h, err
 	}
+	revision := moRevision{uint16(value >> 16), uint16(value)}
 	// From spec: "A program seeing an unexpected major revision
 	// number should stop reading the MO file entirely".
 	if revision.Major != 0 && revision.Major != 1 {
-		return h, errors.New("Unexpected major revision number.")
+		return h, r.errorf(4, "unexpected major revision number %d", revision.Major)
 	}
 	// byte 8:  number of messages.
 	// byte 12: index of messages table.
 	// byte 16: index of translations table.
-	// byte 20: size of hashing table (ignored).
-	// byte 24: offset of hashing table (ignored).
+	// byte 20: size of hashing table.
+	// byte 24: offset of hashing table.
 	if err := binary.Read(r.reader, r.order, &h); err != nil {
 		return h, err
 	}
 	// Increment offset by the amount we have read.
-	r.offset += 28
+	r.offset += 20
+	if err := r.checkTable(12, h.IdTableOffset, h.MsgCount, 8); err != nil {
+		return h, err
+	}
+	if err := r.checkTable(16, h.StrTableOffset, h.MsgCount, 8); err != nil {
+		return h, err
+	}
+	if err := r.checkTable(24, h.HashOffset, h.HashSize, 4); err != nil {
+		return h, err
+	}
+	if revision.Minor >= 1 {
+		if r.size < 48 {
+			return h, r.errorf(28, "file is too short")
+		}
+		// byte 28: number of system-dependent segments.
+		// byte 32: offset of segments table.
+		// byte 36: number of system-dependent strings.
+		// byte 40: offset of system-dependent messages table.
+		// byte 44: offset of system-dependent translations table.
+		if err := binary.Read(r.reader, r.order, &r.sysdep); err != nil {
+			return h, err
+		}
+		r.offset += 20
+		s := &r.sysdep
+		if err := r.checkTable(32, s.SegmentOffset, s.SegmentCount, 8); err != nil {
+			return h, err
+		}
+		if err := r.checkTable(40, s.IdTableOffset, s.StringCount, 4); err != nil {
+			return h, err
+		}
+		if err := r.checkTable(44, s.StrTableOffset, s.StringCount, 4); err != nil {
+			return h, err
+		}
+	}
 	return h, nil
 }
 
+// readSize finds the size of the stream, from the position in which it was
+// initially provided.
+func (r *moReader) readSize() error {
+	start, err := r.reader.Seek(0, 1)
+	if err != nil {
+		return err
+	}
+	end, err := r.reader.Seek(0, 2)
+	if err != nil {
+		return err
+	}
+	if _, err := r.reader.Seek(start, 0); err != nil {
+		return err
+	}
+	r.offset = 0
+	r.size = end - start
+	return nil
+}
+
 // readMessage reads a message or translation at the given stream offset.
 func (r *moReader) readMessage(tableOffset uint32) ([]byte, error) {
 	// Get message length and position.
+	if err := r.check(tableOffset, 8); err != nil {
+		return nil, err
+	}
 	if err := r.seek(int64(tableOffset)); err != nil {
 		return nil, err
 	}
@@ -210,18 +560,63 @@ func (r *moReader) readMessage(tableOffset uint32) ([]byte, error) {
 	// Increment offset by the amount we have read.
 	r.offset += 8
 	// Get the message itself.
-	if err := r.seek(int64(pos.Offset)); err != nil {
+	return r.readBytes(pos.Offset, pos.Size)
+}
+
+// readBytes reads size bytes at the given stream offset.
+func (r *moReader) readBytes(offset, size uint32) ([]byte, error) {
+	// Check before allocating, as sizes come from the file.
+	if err := r.check(offset, size); err != nil {
+		return nil, err
+	}
+	if err := r.seek(int64(offset)); err != nil {
 		return nil, err
 	}
-	bytes := make([]byte, pos.Size)
-	if err := binary.Read(r.reader, r.order, bytes); err != nil {
+	bytes := make([]byte, size)
+	if _, err := io.ReadFull(r.reader, bytes); err != nil {
 		return nil, err
 	}
 	// Increment offset by the amount we have read.
-	r.offset += int64(pos.Size)
+	r.offset += int64(size)
 	return bytes, nil
 }
 
+// readUint32 reads a number at the given stream offset.
+func (r *moReader) readUint32(offset uint32) (uint32, error) {
+	if err := r.check(offset, 4); err != nil {
+		return 0, err
+	}
+	if err := r.seek(int64(offset)); err != nil {
+		return 0, err
+	}
+	var v uint32
+	if err := binary.Read(r.reader, r.order, &v); err != nil {
+		return 0, err
+	}
+	// Increment offset by the amount we have read.
+	r.offset += 4
+	return v, nil
+}
+
+// check returns an error if size bytes at the given stream offset are not
+// inside the stream.
+func (r *moReader) check(offset, size uint32) error {
+	if int64(offset)+int64(size) > r.size {
+		return r.errorf(int64(offset), "%d bytes exceed the file size of %d bytes", size, r.size)
+	}
+	return nil
+}
+
+// checkTable returns an error if a table of count entries of the given
+// size is not inside the stream. field is the offset of the header field
+// that stores the table offset.
+func (r *moReader) checkTable(field int64, offset, count, size uint32) error {
+	if int64(offset)+int64(count)*int64(size) > r.size {
+		return r.errorf(field, "table of %d entries at offset %d exceeds the file size of %d bytes", count, offset, r.size)
+	}
+	return nil
+}
+
 // seek seeks the underlying reader relatively to the position in which
 // it was initially provided.
 func (r *moReader) seek(offset int64) error {
@@ -232,151 +627,398 @@ func (r *moReader) seek(offset int64) error {
 	return nil
 }
 
+func (r *moReader) errorf(offset int64, format string, args ...interface{}) error {
+	return &MoFormatError{Offset: offset, Index: -1, Msg: fmt.Sprintf(format, args...)}
+}
+
+// withIndex sets the message index of a format error, if it isn't set.
+func withIndex(err error, idx int) error {
+	if e, ok := err.(*MoFormatError); ok && e.Index < 0 {
+		e.Index = idx
+	}
+	return err
+}
+
 // ----------------------------------------------------------------------------
 
 // moWriter writes a MO file.
 type moWriter struct {
-	writer io.WriteSeeker // stream writer
-	iter   Iterator       // messages to write
-	offset int64          // relative offset of the stream
+	writer   *bufio.Writer    // buffered stream writer
+	iter     Iterator         // messages to write
+	order    binary.ByteOrder // byte order of the stream
+	revision moRevision       // file format revision
+	noHash   bool             // whether to omit the hash table
+	offset   int64            // amount of bytes written
 }
 
 // writeAll writes the whole MO file.
 //
+// The layout is the same used by GNU msgfmt: the header is followed by the
+// msgid and msgstr tables, the hash table, all msgids and all msgstrs.
+// Messages are sorted by msgid. If there are system-dependent messages,
+// their tables and strings are placed after the regular ones.
+//
 // Providing the catalog header is left to the catalog implementation.
 func (w *moWriter) writeAll() error {
-	msgCount := uint32(w.iter.Size())
-	// Write header.
-	h := moHeader{
-		MsgCount:       msgCount,
-		IdTableOffset:  28,
-		StrTableOffset: msgCount*8 + 28,
-	}
-	if err := w.writeHeader(h); err != nil {
+	msgs, err := w.messages()
+	if err != nil {
 		return err
 	}
-	offset := msgCount*16 + 28
-	for i := uint32(0); i < msgCount; i++ {
-		msg, err := w.iter.Next()
-		if err != nil {
+	var ids, strs [][]byte
+	var sysdep []moSysdepString // msgids, then msgstrs
+	for _, msg := range msgs {
+		id, str := encodeMessage(msg)
+		if msg.HasFlag("c-format") {
+			sid, sstr := splitSysdep(id), splitSysdep(str)
+			if len(sid.segments) > 0 || len(sstr.segments) > 0 {
+				sysdep = append(sysdep, sid, sstr)
+				continue
+			}
+		}
+		ids = append(ids, id)
+		strs = append(strs, str)
+	}
+	sort.Sort(moEntries{ids, strs})
+	// Collect the names of system-dependent segments.
+	var segments []string
+	segmentIndex := map[string]uint32{}
+	for _, s := range sysdep {
+		for _, name := range s.segments {
+			if _, ok := segmentIndex[name]; !ok {
+				segmentIndex[name] = uint32(len(segments))
+				segments = append(segments, name)
+			}
+		}
+	}
+	revision := w.revision
+	if len(sysdep) > 0 && revision.Minor == 0 {
+		revision.Minor = 1
+	}
+	// Compute the layout. All offsets must fit in 32 bits.
+	var offset uint64
+	alloc := func(size uint64) uint32 {
+		start := offset
+		offset += size
+		return uint32(start)
+	}
+	msgCount, sysdepCount := uint64(len(ids)), uint64(len(sysdep)/2)
+	alloc(28)
+	if revision.Minor >= 1 {
+		alloc(20)
+	}
+	h := moHeader{MsgCount: uint32(msgCount)}
+	h.IdTableOffset = alloc(msgCount * 8)
+	h.StrTableOffset = alloc(msgCount * 8)
+	if !w.noHash {
+		h.HashSize = hashTableSize(uint32(msgCount + sysdepCount))
+	}
+	h.HashOffset = alloc(uint64(h.HashSize) * 4)
+	sh := moSysdepHeader{
+		SegmentCount: uint32(len(segments)),
+		StringCount:  uint32(sysdepCount),
+	}
+	sh.SegmentOffset = alloc(uint64(len(segments)) * 8)
+	sh.IdTableOffset = alloc(sysdepCount * 4)
+	sh.StrTableOffset = alloc(sysdepCount * 4)
+	sysdepOffsets := make([]uint32, len(sysdep))
+	for i, s := range sysdep {
+		sysdepOffsets[i] = alloc(4 + uint64(len(s.segments)+1)*8)
+	}
+	idOffsets := make([]uint32, len(ids))
+	for i, b := range ids {
+		idOffsets[i] = alloc(uint64(len(b) + 1)) // +1 for the NUL char separator.
+	}
+	strOffsets := make([]uint32, len(strs))
+	for i, b := range strs {
+		strOffsets[i] = alloc(uint64(len(b) + 1))
+	}
+	segmentOffsets := make([]uint32, len(segments))
+	for i, name := range segments {
+		segmentOffsets[i] = alloc(uint64(len(name) + 1))
+	}
+	staticOffsets := make([]uint32, len(sysdep))
+	for i, s := range sysdep {
+		staticOffsets[i] = alloc(uint64(s.staticSize()))
+	}
+	if offset > math.MaxUint32 {
+		return fmt.Errorf("MO file is too large: %d bytes, the maximum is %d.",
+			offset, uint32(math.MaxUint32))
+	}
+	// Write header and tables, in the same order they were laid out.
+	if err := w.writeHeader(h, revision); err != nil {
+		return err
+	}
+	if revision.Minor >= 1 {
+		if err := w.writeAt(28, sh); err != nil {
+			return err
+		}
+	}
+	for i, b := range ids {
+		pos := moPosition{uint32(len(b)), idOffsets[i]}
+		if err := w.writeAt(h.IdTableOffset+uint32(i)*8, pos); err != nil {
 			return err
 		}
-		// Write msgid.
-		b := append(make([]byte, 0), msg.Id...)
-		if msg.Ctxt != nil {
-			b = append(b[:0], append(append(msg.Ctxt, eotBytes...), b...)...)
+	}
+	for i, b := range strs {
+		pos := moPosition{uint32(len(b)), strOffsets[i]}
+		if err := w.writeAt(h.StrTableOffset+uint32(i)*8, pos); err != nil {
+			return err
 		}
-		if msg.IdPlural != nil {
-			b = append(append(b, nulBytes...), msg.IdPlural...)
+	}
+	sysdepIds := make([][]byte, 0, sysdepCount)
+	for i := 0; i < len(sysdep); i += 2 {
+		sysdepIds = append(sysdepIds, sysdep[i].expand())
+	}
+	if err := w.writeHashTable(h, ids, sysdepIds); err != nil {
+		return err
+	}
+	for i, name := range segments {
+		pos := moPosition{uint32(len(name) + 1), segmentOffsets[i]}
+		if err := w.writeAt(sh.SegmentOffset+uint32(i)*8, pos); err != nil {
+			return err
+		}
+	}
+	// Even sysdep entries are msgids; odd entries are msgstrs.
+	for i := 0; i < len(sysdep); i += 2 {
+		if err := w.writeAt(sh.IdTableOffset+uint32(i/2)*4, sysdepOffsets[i]); err != nil {
+			return err
+		}
+	}
+	for i := 1; i < len(sysdep); i += 2 {
+		if err := w.writeAt(sh.StrTableOffset+uint32(i/2)*4, sysdepOffsets[i]); err != nil {
+			return err
+		}
+	}
+	for i, s := range sysdep {
+		if err := w.writeAt(sysdepOffsets[i], s.descriptor(staticOffsets[i], segmentIndex)); err != nil {
+			return err
+		}
+	}
+	// Write strings.
+	for i, b := range ids {
+		if err := w.writeString(idOffsets[i], b); err != nil {
+			return err
 		}
-		if err = w.writeMessage(h.IdTableOffset+i*8, offset, b); err != nil {
+	}
+	for i, b := range strs {
+		if err := w.writeString(strOffsets[i], b); err != nil {
 			return err
 		}
-		offset += uint32(len(b) + 1) // +1 for the NUL char separator.
-		// Write msgstr.
-		if msg.IdPlural == nil {
-			b = msg.Str
-		} else {
-			b = bytes.Join(msg.StrPlural, nulBytes)
+	}
+	for i, name := range segments {
+		if err := w.writeString(segmentOffsets[i], []byte(name)); err != nil {
+			return err
 		}
-		if err = w.writeMessage(h.StrTableOffset+i*8, offset, b); err != nil {
+	}
+	for i, s := range sysdep {
+		if err := w.writeString(staticOffsets[i], s.static()); err != nil {
 			return err
 		}
-		offset += uint32(len(b) + 1) // +1 for the NUL char separator.
 	}
 	return nil
 }
 
-// writeHeader writes the MO file header.
-func (w *moWriter) writeHeader(header moHeader) error {
-	if err := w.seek(0); err != nil {
-		return err
+// messages returns the messages to be written. Obsolete messages are
+// skipped, as they only exist in PO files.
+func (w *moWriter) messages() ([]*Message, error) {
+	msgs := make([]*Message, 0, w.iter.Size())
+	for {
+		msg, err := w.iter.Next()
+		if err == io.EOF {
+			return msgs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if msg.Meta == nil || !msg.Meta.Obsolete {
+			msgs = append(msgs, msg)
+		}
 	}
-	// byte 0: magic number.
-	if err := binary.Write(w.writer, binary.LittleEndian, littleEndian); err != nil {
+}
+
+// writeHeader writes the MO file header.
+func (w *moWriter) writeHeader(header moHeader, revision moRevision) error {
+	// byte 0: magic number, which identifies the byte order.
+	if err := w.writeAt(0, littleEndian); err != nil {
 		return err
 	}
 	// byte 4: major+minor revision number.
-	if err := binary.Write(w.writer, binary.LittleEndian, moRevision{}); err != nil {
+	if err := w.writeAt(4, revision.value()); err != nil {
 		return err
 	}
 	// bytes 8-24: header values.
-	if err := binary.Write(w.writer, binary.LittleEndian, header); err != nil {
-		return err
+	return w.writeAt(8, header)
+}
+
+// writeHashTable writes the hash table used to find msgids.
+//
+// System-dependent msgids are inserted after the regular ones, at
+// MsgCount+1+j for the jth one, as GNU libintl does when it loads a file.
+// They are hashed as expanded by this package; GNU libintl inserts its own
+// expansion too, and skips entries that don't match.
+func (w *moWriter) writeHashTable(header moHeader, ids, sysdepIds [][]byte) error {
+	if header.HashSize == 0 {
+		return nil
 	}
-	// Increment offset by the amount we have written.
-	w.offset += 28
-	return nil
+	table := make([]uint32, header.HashSize)
+	for i, id := range append(ids[:len(ids):len(ids)], sysdepIds...) {
+		hash := hashString(msgidKey(id))
+		idx := hash % header.HashSize
+		if table[idx] != 0 {
+			incr := 1 + hash%(header.HashSize-2)
+			for table[idx] != 0 {
+				if idx >= header.HashSize-incr {
+					idx -= header.HashSize - incr
+				} else {
+					idx += incr
+				}
+			}
+		}
+		table[idx] = uint32(i) + 1
+	}
+	return w.writeAt(header.HashOffset, table)
 }
 
-// writeMessage writes a message or translation at the given stream offsets.
-func (w *moWriter) writeMessage(tableOffset, msgOffset uint32, bytes []byte) error {
-	// Write message length and position.
-	if err := w.seek(int64(tableOffset)); err != nil {
-		return err
+// writeAt writes data at the given stream offset, using the byte order of
+// the stream. Data must be written sequentially, so offset is only used to
+// check that the layout is respected.
+func (w *moWriter) writeAt(offset uint32, data interface{}) error {
+	if int64(offset) != w.offset {
+		return fmt.Errorf("Unexpected MO file offset %d, expected %d.", offset, w.offset)
 	}
-	pos := moPosition{uint32(len(bytes)), msgOffset}
-	if err := binary.Write(w.writer, binary.LittleEndian, pos); err != nil {
+	if err := binary.Write(w.writer, w.order, data); err != nil {
 		return err
 	}
 	// Increment offset by the amount we have written.
-	w.offset += 8
-	// Write the message itself.
-	if err := w.seek(int64(msgOffset)); err != nil {
+	w.offset += int64(binary.Size(data))
+	return nil
+}
+
+// writeString writes a string followed by a NUL char at the given stream
+// offset.
+func (w *moWriter) writeString(offset uint32, b []byte) error {
+	if err := w.writeAt(offset, b); err != nil {
 		return err
 	}
-	bytes = append(bytes, nulBytes...)
-	if err := binary.Write(w.writer, binary.LittleEndian, bytes); err != nil {
+	if _, err := w.writer.Write(nulBytes); err != nil {
 		return err
 	}
 	// Increment offset by the amount we have written.
-	w.offset += int64(len(bytes))
+	w.offset += 1
 	return nil
 }
 
-// seek seeks the underlying writer relatively to the position in which
-// it was initially provided.
-func (w *moWriter) seek(offset int64) error {
-	if _, err := w.writer.Seek(offset-w.offset, 1); err != nil {
-		return err
+// ----------------------------------------------------------------------------
+
+// encodeMessage returns the msgid and msgstr of a message as stored in MO
+// files: the context is prepended to the msgid, separated by EOT, and
+// plural forms are joined with NUL.
+func encodeMessage(msg *Message) (id, str []byte) {
+	if msg.Ctxt != nil {
+		id = append(append(id, msg.Ctxt...), eotBytes...)
 	}
-	w.offset = offset
-	return nil
+	id = append(id, msg.Id...)
+	if msg.IdPlural == nil {
+		return id, msg.Str
+	}
+	id = append(append(id, nulBytes...), msg.IdPlural...)
+	return id, bytes.Join(msg.StrPlural, nulBytes)
 }
 
-// ----------------------------------------------------------------------------
+// decodeMessage returns a message given its msgid and msgstr, as stored in
+// MO files.
+func decodeMessage(id, str []byte) *Message {
+	msg := Message{Id: id, Str: str}
+	// Is this a context message?
+	if idx := bytes.Index(msg.Id, eotBytes); idx != -1 {
+		msg.Ctxt = msg.Id[:idx]
+		msg.Id = msg.Id[idx+1:]
+	}
+	// Is this a plural message?
+	if idx := bytes.Index(msg.Id, nulBytes); idx != -1 {
+		msg.IdPlural = msg.Id[idx+1:]
+		msg.Id = msg.Id[:idx]
+		msg.StrPlural = bytes.Split(msg.Str, nulBytes)
+		msg.Str = nil
+	}
+	return &msg
+}
 
-// bytesToHeader converts the provided bytes into a translations header.
-func bytesToHeader(header []byte) textproto.MIMEHeader {
-	reader := bufio.NewReader(bytes.NewReader(header))
-	h, err := textproto.NewReader(reader).ReadMIMEHeader()
-	if err == io.EOF {
-		return h
+// msgidKey returns the part of an encoded msgid used for lookups, without
+// the plural msgid.
+func msgidKey(id []byte) []byte {
+	if idx := bytes.IndexByte(id, 0); idx != -1 {
+		return id[:idx]
 	}
-	return textproto.MIMEHeader{}
+	return id
 }
 
-// headerToBytes converts the provided translations header into bytes.
-func headerToBytes(header textproto.MIMEHeader) []byte {
-	if header != nil {
-		b := new(bytes.Buffer)
-		for key, values := range header {
-			for _, value := range values {
-				// TODO: should we escape key or value somehow?
-				b.WriteString(fmt.Sprintf("%s: %s\n", key, value))
-			}
+// moEntries sorts encoded msgids and msgstrs by msgid.
+type moEntries struct {
+	ids, strs [][]byte
+}
+
+func (e moEntries) Len() int {
+	return len(e.ids)
+}
+
+func (e moEntries) Less(i, j int) bool {
+	return bytes.Compare(e.ids[i], e.ids[j]) < 0
+}
+
+func (e moEntries) Swap(i, j int) {
+	e.ids[i], e.ids[j] = e.ids[j], e.ids[i]
+	e.strs[i], e.strs[j] = e.strs[j], e.strs[i]
+}
+
+// hashString returns the hash value of a msgid, using the same algorithm
+// as GNU gettext (a variant of the PJW hash).
+func hashString(b []byte) uint32 {
+	return hashMore(0, bytesToString(b))
+}
+
+// hashMore continues the computation of hashString over s.
+func hashMore(hval uint32, s string) uint32 {
+	for i := 0; i < len(s) && s[i] != 0; i++ {
+		hval = hval<<4 + uint32(s[i])
+		if g := hval & 0xf0000000; g != 0 {
+			hval ^= g >> 24
+			hval ^= g
 		}
-		return b.Bytes()
 	}
-	return nil
+	return hval
+}
+
+// hashTableSize returns the size of the hash table for the given amount of
+// messages, the same computed by GNU msgfmt: the next odd prime after 4/3
+// of the amount of messages, but at least 3.
+func hashTableSize(n uint32) uint32 {
+	size := (n * 4 / 3) | 1
+	for !isPrime(size) {
+		size += 2
+	}
+	if size <= 2 {
+		size = 3
+	}
+	return size
+}
+
+// isPrime reports whether an odd number is prime.
+func isPrime(n uint32) bool {
+	div, sq := uint32(3), uint32(9)
+	for sq < n && n%div != 0 {
+		div++
+		sq += 4 * div
+		div++
+	}
+	return n%div != 0
 }
 
-// TODO: make this public, and use it.
-// multiError groups non-fatal errors occurred when reading or writing gettext
-// files.
-type multiError []error
+// MultiError groups non-fatal errors occurred when reading or writing
+// gettext files.
+type MultiError []error
 
-func (m multiError) Error() string {
+func (m MultiError) Error() string {
 	s, n := "", 0
 	for _, e := range m {
 		if e != nil {
@@ -0,0 +1,60 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package gettext
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func FuzzReadMo(f *testing.F) {
+	b, err := decode([]byte(gnuMoData))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(b)
+	sysdep := new(bytes.Buffer)
+	err = WriteMoOptions(sysdep, ReadPo(strings.NewReader(sysdepPoData)), &MoOptions{MinorRevision: 1})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(sysdep.Bytes())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		iter := ReadMoOptions(bytes.NewReader(data), &MoOptions{MaxSize: 1 << 20})
+		size := iter.Size()
+		count := 0
+		for {
+			msg, err := iter.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				checkMoError(t, err)
+				return
+			}
+			if count++; count > size {
+				t.Fatalf("Expected at most %d messages.", size)
+			}
+			// The lookup may go through other, invalid, messages.
+			if _, err := LookupMo(bytes.NewReader(data), msg.Ctxt, msg.Id); err != nil {
+				checkMoError(t, err)
+			}
+		}
+		if count != size {
+			t.Fatalf("Expected %d messages, got %d.", size, count)
+		}
+	})
+}
+
+func checkMoError(t *testing.T, err error) {
+	if _, ok := err.(*MoFormatError); !ok {
+		t.Fatalf("Expected a format error, got %T: %v.", err, err)
+	}
+}
@@ -6,10 +6,15 @@ package gettext
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/base64"
+	"encoding/binary"
+	"io"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -78,9 +83,12 @@ func TestReadMo(t *testing.T) {
 	equalString(c.Singular("mullusk"), "bacon")
 	equalString(c.Singular("Raymond Luxury Yach-t"), "Throatwobbler Mangrove")
 	equalString(c.Singular("nudge nudge"), "wink wink")
-	// TODO ngettext
-	//equalString(c.GetPlural("There is %s file", 1), "Hay %s fichero")
-	//equalString(c.GetPlural("There is %s file", 2), "Hay %s ficheros")
+	// ngettext
+	equalString(c.Plural("There is %s file", "There are %s files", 1), "Hay %s fichero")
+	equalString(c.Plural("There is %s file", "There are %s files", 2), "Hay %s ficheros")
+	equalString(c.Plural("There is %s file", "There are %s files", 2, "1"), "Hay 1 ficheros")
+	equalString(c.Plural("There is %s cat", "There are %s cats", 1), "There is %s cat")
+	equalString(c.Plural("There is %s cat", "There are %s cats", 0), "There are %s cats")
 }
 
 func TestWriteMo(t *testing.T) {
@@ -120,7 +128,505 @@ func TestWriteMo(t *testing.T) {
 	equalString(c.Singular("mullusk"), "bacon")
 	equalString(c.Singular("Raymond Luxury Yach-t"), "Throatwobbler Mangrove")
 	equalString(c.Singular("nudge nudge"), "wink wink")
-	// TODO ngettext
-	//equalString(c.GetPlural("There is %s file", 1), "Hay %s fichero")
-	//equalString(c.GetPlural("There is %s file", 2), "Hay %s ficheros")
+
+	// The same lookups in the catalog read back from the written file.
+	equalString(c2.Singular("albatross"), "albatross")
+	equalString(c2.Singular("mullusk"), "bacon")
+	equalString(c2.Singular("Raymond Luxury Yach-t"), "Throatwobbler Mangrove")
+	equalString(c2.Singular("nudge nudge"), "wink wink")
+	// ngettext
+	equalString(c2.Plural("There is %s file", "There are %s files", 1), "Hay %s fichero")
+	equalString(c2.Plural("There is %s file", "There are %s files", 2), "Hay %s ficheros")
+	equalString(c2.Plural("There is %s file", "There are %s files", 2, "1"), "Hay 1 ficheros")
+	equalString(c2.Plural("There is %s cat", "There are %s cats", 1), "There is %s cat")
+	equalString(c2.Plural("There is %s cat", "There are %s cats", 0), "There are %s cats")
+}
+
+func BenchmarkReadMo(b *testing.B) {
+	data, err := decode([]byte(gnuMoData))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := NewCatalog().ReadMo(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteMo(b *testing.B) {
+	data, err := decode([]byte(gnuMoData))
+	if err != nil {
+		b.Fatal(err)
+	}
+	c := NewCatalog()
+	if err := c.ReadMo(bytes.NewReader(data)); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := WriteMo(ioutil.Discard, c.Iter()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestLookupMo(t *testing.T) {
+	b, err := decode([]byte(gnuMoData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(polishPoData)); err != nil {
+		t.Fatal(err)
+	}
+	f := newFile("testLookupMo", t)
+	defer os.Remove(f.Name())
+	if err := WriteMo(f, c.Iter()); err != nil {
+		t.Fatal(err)
+	}
+	written, err := ioutil.ReadFile(f.Name())
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		data       []byte
+		ctxt, id   string
+		str        string
+		strPlural1 string
+	}{
+		// File written by GNU msgfmt.
+		{b, "", "mullusk", "bacon", ""},
+		{b, "", "There is %s file", "", "Hay %s ficheros"},
+		{b, "", "albatross", "", ""},
+		// File written by WriteMo, with hash table.
+		{written, "", "Open", "Otwórz", ""},
+		{written, "door", "Open", "Otwarte", ""},
+		{written, "disk", "%d file", "", "%d pliki na dysku"},
+		{written, "", "Save", "", ""},
+		{written, "", "Missing", "", ""},
+		{written, "window", "Open", "", ""},
+	}
+	for _, test := range tests {
+		var ctxt []byte
+		if test.ctxt != "" {
+			ctxt = []byte(test.ctxt)
+		}
+		msg, err := LookupMo(bytes.NewReader(test.data), ctxt, []byte(test.id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case msg == nil:
+			if test.str != "" || test.strPlural1 != "" {
+				t.Errorf("Message %q not found.", test.id)
+			}
+		case test.strPlural1 != "":
+			if len(msg.StrPlural) < 2 || string(msg.StrPlural[1]) != test.strPlural1 {
+				t.Errorf("Expected %q, got %q.", test.strPlural1, msg.StrPlural)
+			}
+		case string(msg.Str) != test.str:
+			t.Errorf("Expected %q, got %q.", test.str, msg.Str)
+		}
+	}
+}
+
+func TestHashString(t *testing.T) {
+	// Values computed by GNU gettext's hash_string().
+	tests := map[string]uint32{
+		"":                      0,
+		"a":                     0x61,
+		"hello":                 0x6ec32f,
+		"Raymond Luxury Yach-t": 0x67e4e14,
+	}
+	for s, hash := range tests {
+		if h := hashString([]byte(s)); h != hash {
+			t.Errorf("%q: expected %#x, got %#x.", s, hash, h)
+		}
+	}
+	if size := hashTableSize(6); size != 11 {
+		t.Errorf("Expected hash table size 11, got %d.", size)
+	}
+}
+
+func TestWriteMoLayout(t *testing.T) {
+	b, err := decode([]byte(gnuMoData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := newFile("testWriteMoLayout", t)
+	defer os.Remove(f.Name())
+	if err := WriteMo(f, ReadMo(bytes.NewReader(b))); err != nil {
+		t.Fatal(err)
+	}
+	written, err := ioutil.ReadFile(f.Name())
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(written, b) {
+		t.Errorf("Expected the same output as GNU msgfmt.")
+	}
+
+	// System-dependent msgids have hash slots after the regular ones.
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(sysdepPoData)); err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := WriteMo(buf, c.Iter()); err != nil {
+		t.Fatal(err)
+	}
+	written = buf.Bytes()
+	order := binary.LittleEndian
+	count, idTable := order.Uint32(written[8:]), order.Uint32(written[12:])
+	size, offset := order.Uint32(written[20:]), order.Uint32(written[24:])
+	if count != 3 || size != hashTableSize(count+2) {
+		t.Fatalf("Unexpected %d messages and hash table size %d.", count, size)
+	}
+	table := make([]uint32, size)
+	used := 0
+	for i := range table {
+		if table[i] = order.Uint32(written[offset+uint32(i)*4:]); table[i] != 0 {
+			used++
+		}
+	}
+	if used != int(count)+2 {
+		t.Errorf("Expected %d hash table entries, got %d.", count+2, used)
+	}
+	probe := func(id []byte, n uint32) {
+		hash := hashString(id)
+		idx, incr := hash%size, 1+hash%(size-2)
+		for i := uint32(0); i < size && table[idx] != 0; i++ {
+			if table[idx] == n {
+				return
+			}
+			idx = (idx + incr) % size
+		}
+		t.Errorf("Entry %d not found for %q.", n, id)
+	}
+	for i := uint32(0); i < count; i++ {
+		length, start := order.Uint32(written[idTable+i*8:]), order.Uint32(written[idTable+i*8+4:])
+		probe(msgidKey(written[start:start+length]), i+1)
+	}
+	probe([]byte("%d file"), count+1)
+	probe([]byte("size\x04%5x bytes, %d blocks"), count+2)
+}
+
+func TestWriteMoOptions(t *testing.T) {
+	b, err := decode([]byte(gnuMoData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		opt    *MoOptions
+		header []byte
+	}{
+		{&MoOptions{ByteOrder: binary.BigEndian},
+			[]byte{0x95, 0x04, 0x12, 0xde, 0, 0, 0, 0, 0, 0, 0, 6}},
+		{&MoOptions{ByteOrder: binary.LittleEndian, MajorRevision: 1, MinorRevision: 2},
+			[]byte{0xde, 0x12, 0x04, 0x95, 2, 0, 1, 0, 6, 0, 0, 0}},
+		{&MoOptions{ByteOrder: binary.BigEndian, MinorRevision: 1, NoHashTable: true},
+			[]byte{0x95, 0x04, 0x12, 0xde, 0, 0, 0, 1, 0, 0, 0, 6}},
+	}
+	for _, test := range tests {
+		f := newFile("testWriteMoOptions", t)
+		if err := WriteMoOptions(f, ReadMo(bytes.NewReader(b)), test.opt); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		written, err := ioutil.ReadFile(f.Name())
+		os.Remove(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(written, test.header) {
+			t.Errorf("Expected header % x, got % x.", test.header, written[:len(test.header)])
+		}
+		if size := test.opt.ByteOrder.Uint32(written[20:]); test.opt.NoHashTable != (size == 0) {
+			t.Errorf("Unexpected hash table size %d.", size)
+		}
+		c := NewCatalog()
+		if err := c.ReadMo(bytes.NewReader(written)); err != nil {
+			t.Fatal(err)
+		}
+		if s := c.Singular("mullusk"); s != "bacon" {
+			t.Errorf("Expected %q, got %q.", "bacon", s)
+		}
+		mc, err := OpenMoBytes(written)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := mc.Plural("There is %s file", "There are %s files", 2); s != "Hay %s ficheros" {
+			t.Errorf("Expected %q, got %q.", "Hay %s ficheros", s)
+		}
+	}
+
+	f := newFile("testWriteMoOptions", t)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := WriteMoOptions(f, ReadMo(bytes.NewReader(b)), &MoOptions{MajorRevision: 2}); err == nil {
+		t.Errorf("Expected error for unsupported major revision.")
+	}
+	// Major revision 2 is rejected by the reader too.
+	b[6] = 2
+	if err := NewCatalog().ReadMo(bytes.NewReader(b)); err == nil {
+		t.Errorf("Expected error for unsupported major revision.")
+	}
+}
+
+var sysdepPoData = `msgid ""
+msgstr ""
+"Plural-Forms: nplurals=2; plural=n != 1;\n"
+
+#, c-format
+msgid "%<PRIu64> file"
+msgid_plural "%<PRIu64> files"
+msgstr[0] "%<PRIu64> fichero"
+msgstr[1] "%<PRIu64> ficheros"
+
+#, c-format
+msgctxt "size"
+msgid "%5<PRIx32> bytes, %I<PRId8> blocks"
+msgstr "%5<PRIx32> octetos, %I<PRId8> bloques"
+
+#, c-format
+msgid "%d%% done"
+msgstr "%d%% hecho"
+
+msgid "Not a <PRIu64> format"
+msgstr "No es un formato %<PRIu64>"
+`
+
+func TestSysdepStrings(t *testing.T) {
+	s := splitSysdep([]byte("%<PRIu64> of %-4I<PRIXMAX>%% <PRIu64>, %<FOO>"))
+	parts := []string{"%", " of %-4", "", "%% <PRIu64>, %<FOO>"}
+	segments := []string{"PRIu64", "I", "PRIXMAX"}
+	if !reflect.DeepEqual(s.segments, segments) || len(s.parts) != len(parts) {
+		t.Fatalf("Unexpected segments %q.", s.segments)
+	}
+	for i, part := range parts {
+		if string(s.parts[i]) != part {
+			t.Errorf("Expected part %q, got %q.", part, s.parts[i])
+		}
+	}
+
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(sysdepPoData)); err != nil {
+		t.Fatal(err)
+	}
+	f := newFile("testSysdepStrings", t)
+	defer os.Remove(f.Name())
+	if err := WriteMo(f, c.Iter()); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(f.Name())
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revision := binary.LittleEndian.Uint32(b[4:]); revision != 1 {
+		t.Errorf("Expected revision 1, got %d.", revision)
+	}
+	if n := binary.LittleEndian.Uint32(b[36:]); n != 2 {
+		t.Errorf("Expected 2 system-dependent strings, got %d.", n)
+	}
+
+	c2 := NewCatalog()
+	if err := c2.ReadMo(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	mc, err := OpenMoBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []interface {
+		Singular(key string, args ...interface{}) string
+		ContextSingular(ctxt, key string, args ...interface{}) string
+		Plural(key, keyPlural string, n int, args ...interface{}) string
+	}{c2, mc} {
+		tests := []struct {
+			got, expected string
+		}{
+			{c.Plural("%d file", "%d files", 2, 2), "2 ficheros"},
+			{c.ContextSingular("size", "%5x bytes, %d blocks", 255, 3), "   ff octetos, 3 bloques"},
+			{c.Singular("%d%% done", 50), "50% hecho"},
+			{c.Singular("Not a <PRIu64> format"), "No es un formato %<PRIu64>"},
+		}
+		for i, test := range tests {
+			if test.got != test.expected {
+				t.Errorf("%d: expected %q, got %q.", i, test.expected, test.got)
+			}
+		}
+	}
+	msg, err := LookupMo(bytes.NewReader(b), []byte("size"), []byte("%5x bytes, %d blocks"))
+	if err != nil || msg == nil {
+		t.Fatalf("Message not found: %v", err)
+	}
+}
+
+// sysdepCollision returns a copy of the little-endian MO file b whose hash
+// table has the first system-dependent message, at MsgCount+1, in the first
+// slot probed for id. Regular messages are inserted after it, so the probe
+// for id has to go past it. GNU libintl builds this layout in memory.
+func sysdepCollision(t *testing.T, b []byte, id string) []byte {
+	b = append([]byte(nil), b...)
+	order := binary.LittleEndian
+	count, idTable := order.Uint32(b[8:]), order.Uint32(b[12:])
+	size, offset := order.Uint32(b[20:]), order.Uint32(b[24:])
+	if size <= count+1 {
+		t.Fatalf("Hash table too small: %d slots for %d messages.", size, count)
+	}
+	table := make([]uint32, size)
+	table[hashString([]byte(id))%size] = count + 1
+	for i := uint32(0); i < count; i++ {
+		length, start := order.Uint32(b[idTable+i*8:]), order.Uint32(b[idTable+i*8+4:])
+		hash := hashString(msgidKey(b[start : start+length]))
+		idx, incr := hash%size, 1+hash%(size-2)
+		for table[idx] != 0 {
+			idx = (idx + incr) % size
+		}
+		table[idx] = i + 1
+	}
+	for i, n := range table {
+		order.PutUint32(b[offset+uint32(i)*4:], n)
+	}
+	return b
+}
+
+func TestLookupMoSysdepCollision(t *testing.T) {
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(sysdepPoData)); err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := WriteMo(buf, c.Iter()); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		id, str string
+	}{
+		{"%d%% done", "%d%% hecho"},
+		{"Not a <PRIu64> format", "No es un formato %<PRIu64>"},
+	}
+	for _, test := range tests {
+		b := sysdepCollision(t, buf.Bytes(), test.id)
+		msg, err := LookupMo(bytes.NewReader(b), nil, []byte(test.id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg == nil {
+			t.Errorf("Message %q not found.", test.id)
+		} else if string(msg.Str) != test.str {
+			t.Errorf("Expected %q, got %q.", test.str, msg.Str)
+		}
+		// System-dependent messages are still found.
+		msg, err = LookupMo(bytes.NewReader(b), nil, []byte("%d file"))
+		if err != nil || msg == nil {
+			t.Errorf("Message %q not found: %v", "%d file", err)
+		}
+		mc, err := OpenMoBytes(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := mc.Singular(test.id); s != test.str {
+			t.Errorf("Expected %q, got %q.", test.str, s)
+		}
+		if s := mc.Plural("%d file", "%d files", 2); s != "%d ficheros" {
+			t.Errorf("Expected %q, got %q.", "%d ficheros", s)
+		}
+	}
+}
+
+func TestWriteMoStream(t *testing.T) {
+	b, err := decode([]byte(gnuMoData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A plain io.Writer, with no Seek method.
+	buf := new(bytes.Buffer)
+	w := struct{ io.Writer }{buf}
+	if err := WriteMo(w, ReadMo(bytes.NewReader(b))); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Errorf("Expected the same output as GNU msgfmt.")
+	}
+	// Compressed stream.
+	buf.Reset()
+	zw := gzip.NewWriter(buf)
+	if err := WriteMo(zw, ReadMo(bytes.NewReader(b))); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(written, b) {
+		t.Errorf("Expected the same output as GNU msgfmt.")
+	}
+}
+
+func TestReadMoErrors(t *testing.T) {
+	b, err := decode([]byte(gnuMoData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := func(offset int, v uint32) []byte {
+		c := append([]byte{}, b...)
+		binary.LittleEndian.PutUint32(c[offset:], v)
+		return c
+	}
+	tests := []struct {
+		data    []byte
+		maxSize int64
+		offset  int64
+		index   int
+	}{
+		// Bad magic number.
+		{corrupt(0, 0x12345678), 0, 0, -1},
+		// Unknown major revision.
+		{corrupt(4, 2<<16), 0, 4, -1},
+		// Truncated header.
+		{b[:20], 0, 0, -1},
+		// Too many messages for the tables.
+		{corrupt(8, 0x10000000), 0, 12, -1},
+		// Hash table out of bounds.
+		{corrupt(24, uint32(len(b))), 0, 24, -1},
+		// First msgid claims to be 4 GB long.
+		{corrupt(28, 0xffffffff), 0, int64(binary.LittleEndian.Uint32(b[32:])), 0},
+		// Second msgstr points past the end of the file.
+		{corrupt(int(binary.LittleEndian.Uint32(b[16:]))+12, uint32(len(b))), 0, int64(len(b)), 1},
+		// File larger than the limit.
+		{b, int64(len(b)) - 1, 0, -1},
+	}
+	for i, test := range tests {
+		iter := ReadMoOptions(bytes.NewReader(test.data), &MoOptions{MaxSize: test.maxSize})
+		var err error
+		for err == nil {
+			_, err = iter.Next()
+		}
+		e, ok := err.(*MoFormatError)
+		if !ok {
+			t.Errorf("%d: Expected a format error, got %v.", i, err)
+		} else if e.Offset != test.offset || e.Index != test.index {
+			t.Errorf("%d: Expected error at offset %d, message %d, got %v.", i, test.offset, test.index, e)
+		}
+	}
+	// LookupMo checks the file too. The header is the first message.
+	if _, err := LookupMo(bytes.NewReader(corrupt(28, 0xffffffff)), nil, []byte("")); err == nil {
+		t.Errorf("Expected a format error.")
+	}
 }
@@ -0,0 +1,373 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/textproto"
+	"unsafe"
+)
+
+// OpenMo returns a catalog that looks up messages directly in a MO file of
+// the given size, read from r on demand.
+//
+// Unlike Catalog, messages are not loaded in memory: each lookup reads only
+// the table entries and strings it needs, using the hash table of the file
+// or a binary search on its sorted msgids.
+func OpenMo(r io.ReaderAt, size int64) (*MoCatalog, error) {
+	return openMo(&moReaderAt{r, size})
+}
+
+// OpenMoBytes returns a catalog that looks up messages directly in the
+// contents of a MO file, such as a memory mapped or embedded file.
+//
+// Translations are returned without copying, so b must not be modified
+// while the catalog is in use.
+func OpenMoBytes(b []byte) (*MoCatalog, error) {
+	return openMo(moBytes(b))
+}
+
+// MoCatalog is a read-only catalog backed by a MO file.
+//
+// Its lookup methods behave like the ones from Catalog. Files in other
+// charsets than UTF-8 are supported, as described for Decode, but lookups
+// allocate memory to convert strings. Strings in unsupported charsets are
+// returned as they are.
+type MoCatalog struct {
+	Header textproto.MIMEHeader
+	src    moSource          // file contents
+	order  binary.ByteOrder  // byte order of the file
+	header moHeader          // parsed file header
+	sysdep map[string][]byte // expanded system-dependent messages
+	plural *PluralRule       // rule compiled from the Plural-Forms header
+	conv   *charsetTable     // charset of the file, nil for UTF-8
+}
+
+func openMo(src moSource) (*MoCatalog, error) {
+	r := &moReader{reader: io.NewSectionReader(src, 0, src.Size())}
+	h, err := r.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	c := &MoCatalog{
+		src:    src,
+		order:  r.order,
+		header: h,
+		plural: DefaultPluralRule,
+	}
+	// System-dependent messages are expanded once, as they can't be
+	// looked up in the file.
+	r.header = &h
+	for i := uint32(0); i < r.sysdep.StringCount; i++ {
+		msg, err := r.readSysdepEntry(i)
+		if err != nil {
+			return nil, err
+		}
+		if c.sysdep == nil {
+			c.sysdep = map[string][]byte{}
+		}
+		id, str := encodeMessage(msg)
+		c.sysdep[string(msgidKey(id))] = str
+	}
+	if str, ok := c.lookup("", false, ""); ok {
+		c.Header = bytesToHeader(str)
+		if rule, err := ParsePluralForms(c.Header.Get("Plural-Forms")); err == nil {
+			c.plural = rule
+		}
+		// Strings in unsupported charsets are returned as they are.
+		if c.conv, _ = lookupCharset(CatalogHeader(c.Header).Charset()); c.conv != nil {
+			str, _ = c.conv.decode(str)
+			c.Header = bytesToHeader(str)
+			CatalogHeader(c.Header).SetCharset("UTF-8")
+		}
+	}
+	return c, nil
+}
+
+// Size returns the amount of messages in the catalog.
+func (c *MoCatalog) Size() int {
+	return int(c.header.MsgCount)
+}
+
+// Singular returns a singular string stored in the catalog, optionally
+// formatting it using the provided arguments.
+//
+// If the message is not translated, key is used instead.
+func (c *MoCatalog) Singular(key string, args ...interface{}) string {
+	if str, ok := c.lookup("", false, key); ok && len(str) > 0 {
+		return format(c.text(str), args)
+	}
+	return format(key, args)
+}
+
+// ContextSingular is like Singular, but for a message with the given
+// context (msgctxt).
+func (c *MoCatalog) ContextSingular(ctxt, key string, args ...interface{}) string {
+	if str, ok := c.lookup(ctxt, true, key); ok && len(str) > 0 {
+		return format(c.text(str), args)
+	}
+	return format(key, args)
+}
+
+// Plural returns the plural form for n of a string stored in the catalog,
+// optionally formatting it using the provided arguments.
+//
+// If the message is not translated, key is used when n is 1 and keyPlural
+// otherwise.
+func (c *MoCatalog) Plural(key, keyPlural string, n int, args ...interface{}) string {
+	return format(c.pluralForm("", false, key, keyPlural, n), args)
+}
+
+// ContextPlural is like Plural, but for a message with the given
+// context (msgctxt).
+func (c *MoCatalog) ContextPlural(ctxt, key, keyPlural string, n int, args ...interface{}) string {
+	return format(c.pluralForm(ctxt, true, key, keyPlural, n), args)
+}
+
+// PluralRule returns the rule used to select plural forms, compiled from
+// the Plural-Forms catalog header. If the header is missing or invalid,
+// DefaultPluralRule is returned.
+func (c *MoCatalog) PluralRule() *PluralRule {
+	return c.plural
+}
+
+// pluralForm returns the plural translation for n, or one of the fallbacks.
+func (c *MoCatalog) pluralForm(ctxt string, hasCtxt bool, key, keyPlural string, n int) string {
+	if str, ok := c.lookup(ctxt, hasCtxt, key); ok {
+		idx := c.plural.Index(n)
+		for i := 0; i <= idx; i++ {
+			end := bytes.IndexByte(str, 0)
+			if i == idx {
+				if end != -1 {
+					str = str[:end]
+				}
+				if len(str) > 0 {
+					return c.text(str)
+				}
+				break
+			}
+			if end == -1 {
+				break
+			}
+			str = str[end+1:]
+		}
+	}
+	if n == 1 {
+		return key
+	}
+	return keyPlural
+}
+
+// lookup returns the msgstr stored for a msgid and optional context.
+// Read errors are handled as if the message was not found.
+func (c *MoCatalog) lookup(ctxt string, hasCtxt bool, id string) ([]byte, bool) {
+	if c.conv != nil {
+		// Keys are stored in the charset of the file.
+		b, err := c.conv.encode([]byte(ctxt))
+		if err != nil {
+			return nil, false
+		}
+		ctxt = bytesToString(b)
+		if b, err = c.conv.encode([]byte(id)); err != nil {
+			return nil, false
+		}
+		id = bytesToString(b)
+	}
+	if str, ok := c.lookupStatic(ctxt, hasCtxt, id); ok {
+		return str, true
+	}
+	if c.sysdep != nil {
+		key := id
+		if hasCtxt {
+			key = contextKey(ctxt, id)
+		}
+		str, ok := c.sysdep[key]
+		return str, ok
+	}
+	return nil, false
+}
+
+// lookupStatic returns the msgstr stored in the regular tables for a msgid
+// and optional context.
+func (c *MoCatalog) lookupStatic(ctxt string, hasCtxt bool, id string) ([]byte, bool) {
+	h := &c.header
+	if h.HashSize > 2 {
+		hash := uint32(0)
+		if hasCtxt {
+			hash = hashMore(hashMore(0, ctxt), "\x04")
+		}
+		hash = hashMore(hash, id)
+		idx := hash % h.HashSize
+		incr := 1 + hash%(h.HashSize-2)
+		for i := uint32(0); i < h.HashSize; i++ {
+			n, ok := c.uint32At(h.HashOffset + idx*4)
+			if !ok || n == 0 {
+				return nil, false
+			}
+			// Entries greater than MsgCount are system-dependent
+			// messages, which are looked up in c.sysdep.
+			if n <= h.MsgCount {
+				if cmp, ok := c.compare(n-1, ctxt, hasCtxt, id); ok && cmp == 0 {
+					return c.stringAt(h.StrTableOffset + (n-1)*8)
+				}
+			}
+			if idx >= h.HashSize-incr {
+				idx -= h.HashSize - incr
+			} else {
+				idx += incr
+			}
+		}
+		return nil, false
+	}
+	lo, hi := uint32(0), h.MsgCount
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		cmp, ok := c.compare(mid, ctxt, hasCtxt, id)
+		switch {
+		case !ok:
+			return nil, false
+		case cmp == 0:
+			return c.stringAt(h.StrTableOffset + mid*8)
+		case cmp < 0:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return nil, false
+}
+
+// compare compares the msgid at the given index with a msgid and optional
+// context, ignoring plural msgids.
+func (c *MoCatalog) compare(idx uint32, ctxt string, hasCtxt bool, id string) (int, bool) {
+	b, ok := c.stringAt(c.header.IdTableOffset + idx*8)
+	if !ok {
+		return 0, false
+	}
+	b = msgidKey(b)
+	if hasCtxt {
+		if cmp, done := comparePrefix(b, ctxt); done {
+			return cmp, true
+		}
+		b = b[len(ctxt):]
+		if cmp, done := comparePrefix(b, "\x04"); done {
+			return cmp, true
+		}
+		b = b[1:]
+	}
+	if cmp, done := comparePrefix(b, id); done {
+		return cmp, true
+	}
+	if len(b) > len(id) {
+		return 1, true
+	}
+	return 0, true
+}
+
+// text converts a string from the file to UTF-8.
+func (c *MoCatalog) text(b []byte) string {
+	if c.conv != nil {
+		b, _ = c.conv.decode(b)
+	}
+	return bytesToString(b)
+}
+
+// uint32At reads a number at the given offset.
+func (c *MoCatalog) uint32At(offset uint32) (uint32, bool) {
+	b, err := c.src.Bytes(offset, 4)
+	if err != nil {
+		return 0, false
+	}
+	return c.order.Uint32(b), true
+}
+
+// stringAt reads the string described by the table entry at the given
+// offset.
+func (c *MoCatalog) stringAt(offset uint32) ([]byte, bool) {
+	b, err := c.src.Bytes(offset, 8)
+	if err != nil {
+		return nil, false
+	}
+	b, err = c.src.Bytes(c.order.Uint32(b[4:]), c.order.Uint32(b))
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// ----------------------------------------------------------------------------
+
+// moSource provides random access to the contents of a MO file.
+type moSource interface {
+	io.ReaderAt
+	// Size returns the size of the file.
+	Size() int64
+	// Bytes returns n bytes at the given offset.
+	Bytes(offset, n uint32) ([]byte, error)
+}
+
+// moBytes is a MO file stored in memory.
+type moBytes []byte
+
+func (b moBytes) ReadAt(p []byte, off int64) (int, error) {
+	return bytes.NewReader(b).ReadAt(p, off)
+}
+
+func (b moBytes) Size() int64 {
+	return int64(len(b))
+}
+
+func (b moBytes) Bytes(offset, n uint32) ([]byte, error) {
+	if uint64(offset)+uint64(n) > uint64(len(b)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b[offset : offset+n : offset+n], nil
+}
+
+// moReaderAt is a MO file read on demand.
+type moReaderAt struct {
+	io.ReaderAt
+	size int64
+}
+
+func (r *moReaderAt) Size() int64 {
+	return r.size
+}
+
+func (r *moReaderAt) Bytes(offset, n uint32) ([]byte, error) {
+	if int64(offset)+int64(n) > r.size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := make([]byte, n)
+	// A full read may return io.EOF when it ends at the end of the file.
+	if n, err := r.ReadAt(b, int64(offset)); n < len(b) {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// comparePrefix compares the beginning of b with s. It returns done=true if
+// b doesn't start with s, along with the comparison result.
+func comparePrefix(b []byte, s string) (cmp int, done bool) {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case i == len(b) || b[i] < s[i]:
+			return -1, true
+		case b[i] > s[i]:
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+// bytesToString converts b to a string without copying it. The bytes must
+// not be modified afterwards.
+func bytesToString(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
@@ -0,0 +1,131 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// eofReaderAt returns io.EOF along with reads that end at the end of the
+// data, as allowed by the io.ReaderAt contract.
+type eofReaderAt struct {
+	b []byte
+}
+
+func (r eofReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(r.b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.b[off:])
+	if off+int64(n) == int64(len(r.b)) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func TestMoCatalog(t *testing.T) {
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(polishPoData)); err != nil {
+		t.Fatal(err)
+	}
+	f := newFile("testMoCatalog", t)
+	defer os.Remove(f.Name())
+	if err := WriteMo(f, c.Iter()); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without hash table, lookups use a binary search.
+	noHash := append([]byte{}, b...)
+	copy(noHash[20:24], []byte{0, 0, 0, 0})
+
+	mc1, err := OpenMoBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc2, err := OpenMo(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc3, err := OpenMoBytes(noHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without the final NUL char, the last string ends the file.
+	mc4, err := OpenMo(eofReaderAt{b[:len(b)-1]}, int64(len(b)-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mc := range []*MoCatalog{mc1, mc2, mc3, mc4} {
+		if lang := mc.Header.Get("Language"); lang != "pl" {
+			t.Errorf("Expected language %q, got %q.", "pl", lang)
+		}
+		if n := mc.PluralRule().NPlurals; n != 3 {
+			t.Errorf("Expected 3 plural forms, got %d.", n)
+		}
+		tests := []struct {
+			got, expected string
+		}{
+			{mc.Singular("Open"), "Otwórz"},
+			{mc.ContextSingular("door", "Open"), "Otwarte"},
+			{mc.ContextSingular("window", "Open"), "Open"},
+			{mc.ContextSingular("", "Open"), "Open"},
+			{mc.Singular("Save"), "Save"},
+			{mc.Singular("Missing %d", 3), "Missing 3"},
+			{mc.Plural("%d file", "%d files", 1, 1), "1 plik"},
+			{mc.Plural("%d file", "%d files", 3, 3), "3 pliki"},
+			{mc.Plural("%d file", "%d files", 5, 5), "5 plików"},
+			{mc.ContextPlural("disk", "%d file", "%d files", 12, 12), "12 plików na dysku"},
+			{mc.ContextPlural("tape", "%d file", "%d files", 1, 1), "1 file"},
+			{mc.Plural("%d dir", "%d dirs", 2, 2), "2 dirs"},
+		}
+		for i, test := range tests {
+			if test.got != test.expected {
+				t.Errorf("%d: expected %q, got %q.", i, test.expected, test.got)
+			}
+		}
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		mc1.ContextSingular("door", "Open")
+		mc1.Plural("%d file", "%d files", 5)
+		mc3.Singular("Open")
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v.", allocs)
+	}
+}
+
+func TestMoCatalogErrors(t *testing.T) {
+	b, err := decode([]byte(gnuMoData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenMoBytes(b[:100]); err == nil {
+		t.Errorf("Expected error for truncated file.")
+	}
+	if _, err := OpenMoBytes([]byte("not a MO file")); err == nil {
+		t.Errorf("Expected error for invalid file.")
+	}
+	// Strings out of bounds are not found.
+	mc, err := OpenMoBytes(b[:len(b)-20])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := mc.Singular("nudge nudge"); s != "nudge nudge" {
+		t.Errorf("Expected %q, got %q.", "nudge nudge", s)
+	}
+	if s := mc.Singular("Raymond Luxury Yach-t"); s != "Throatwobbler Mangrove" {
+		t.Errorf("Expected %q, got %q.", "Throatwobbler Mangrove", s)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseAcceptLanguage parses the value of an Accept-Language HTTP header,
+// such as "pt-BR, pt;q=0.8, en;q=0.5", and returns the language ranges
+// sorted by decreasing quality. Ranges with the same quality keep their
+// order. Ranges with quality 0 or invalid parameters are dropped.
+func ParseAcceptLanguage(s string) []string {
+	type languageRange struct {
+		name string
+		q    float64
+	}
+	var ranges []languageRange
+	for _, part := range strings.Split(s, ",") {
+		fields := strings.Split(part, ";")
+		r := languageRange{name: strings.TrimSpace(fields[0]), q: 1}
+		valid := r.name != ""
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") && !strings.HasPrefix(param, "Q=") {
+				continue
+			}
+			q, err := strconv.ParseFloat(param[2:], 64)
+			if err != nil || q < 0 || q > 1 {
+				valid = false
+			}
+			r.q = q
+		}
+		if valid && r.q > 0 {
+			ranges = append(ranges, r)
+		}
+	}
+	// Insertion sort, which is stable and fine for the usual few ranges.
+	for i := 1; i < len(ranges); i++ {
+		for j := i; j > 0 && ranges[j].q > ranges[j-1].q; j-- {
+			ranges[j], ranges[j-1] = ranges[j-1], ranges[j]
+		}
+	}
+	var names []string
+	for _, r := range ranges {
+		names = append(names, r.name)
+	}
+	return names
+}
+
+// Negotiate returns the best available locale for a list of requested
+// locales, in order of preference, and whether one was found. The returned
+// locale is one of the available ones, as given.
+//
+// Each requested locale matches an available locale that is the same or
+// one of its parents, as given by Locale.Parents, so "pt-BR" matches "pt".
+// If no requested locale matches this way, an available locale with the
+// language of a requested one is used, so "pt" matches "pt-BR". Locales
+// with the same script are preferred, then those with the same region,
+// then the most generic ones, regardless of their order. The script of
+// Chinese is implied by the region when missing, so "zh-TW" matches
+// "zh-Hant" rather than "zh-Hans".
+// The "*" range and invalid locales are ignored.
+func Negotiate(requested, available []string) (string, bool) {
+	canonical := map[string]string{}
+	var locales []Locale
+	var names []string
+	for _, name := range available {
+		l, err := ParseLocale(name)
+		if err != nil {
+			continue
+		}
+		if _, ok := canonical[l.String()]; !ok {
+			canonical[l.String()] = name
+			locales = append(locales, l)
+			names = append(names, name)
+		}
+	}
+	var wanted []Locale
+	for _, name := range requested {
+		if l, err := ParseLocale(name); err == nil {
+			wanted = append(wanted, l)
+		}
+	}
+	for _, l := range wanted {
+		for _, candidate := range append([]Locale{l}, l.Parents()...) {
+			if name, ok := canonical[candidate.String()]; ok {
+				return name, true
+			}
+		}
+	}
+	// Fall back to the closest available locale of the language.
+	for _, l := range wanted {
+		best := -1
+		for i, candidate := range locales {
+			if candidate.Language == l.Language && (best == -1 || betterFallback(l, candidate, locales[best])) {
+				best = i
+			}
+		}
+		if best != -1 {
+			return names[best], true
+		}
+	}
+	return "", false
+}
+
+// regionScripts maps locales without script to the script implied by their
+// region, for languages commonly written in more than one.
+var regionScripts = map[string]string{
+	"zh-CN": "Hans",
+	"zh-SG": "Hans",
+	"zh-HK": "Hant",
+	"zh-MO": "Hant",
+	"zh-TW": "Hant",
+}
+
+// impliedScript returns the script of l, or the one implied by its region.
+func impliedScript(l Locale) string {
+	if l.Script != "" {
+		return l.Script
+	}
+	return regionScripts[l.Language+"-"+l.Region]
+}
+
+// betterFallback returns whether a is closer than b to the requested locale
+// l, both having its language. Ties between locales equally close and
+// generic are broken by name, so that the order of the available locales
+// doesn't matter.
+func betterFallback(l, a, b Locale) bool {
+	if script := impliedScript(l); script != "" {
+		if sa, sb := impliedScript(a) == script, impliedScript(b) == script; sa != sb {
+			return sa
+		}
+	}
+	if l.Region != "" {
+		if ra, rb := a.Region == l.Region, b.Region == l.Region; ra != rb {
+			return ra
+		}
+	}
+	sa, sb := a.String(), b.String()
+	if len(sa) != len(sb) {
+		return len(sa) < len(sb)
+	}
+	return sa < sb
+}
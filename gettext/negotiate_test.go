@@ -0,0 +1,85 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected []string
+	}{
+		{"", nil},
+		{"en", []string{"en"}},
+		{"da, en-GB;q=0.8, en;q=0.7", []string{"da", "en-GB", "en"}},
+		{"en;q=0.5, fr, de;q=0.9, pt;q=0.9", []string{"fr", "de", "pt", "en"}},
+		{"en;q=0, fr;q=2, de;q=x, ;q=1, pt", []string{"pt"}},
+		{" zh-Hant-TW ; Q=0.3 , * ;q=0.1", []string{"zh-Hant-TW", "*"}},
+	}
+	for _, test := range tests {
+		if got := ParseAcceptLanguage(test.src); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q: expected %q, got %q.", test.src, test.expected, got)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	available := []string{"en", "pt", "pt_BR", "zh-Hant", "fr-CA", "fr-FR"}
+	tests := []struct {
+		requested []string
+		expected  string
+	}{
+		{[]string{"pt-BR"}, "pt_BR"},
+		{[]string{"pt-PT"}, "pt"},
+		{[]string{"de", "pt-pt"}, "pt"},
+		{[]string{"zh-Hant-HK", "en"}, "zh-Hant"},
+		{[]string{"zh-TW", "en"}, "en"},
+		{[]string{"fr", "de"}, "fr-CA"},
+		{[]string{"*", "invalid!", "EN-us"}, "en"},
+		{[]string{"de", "zh"}, "zh-Hant"},
+		{[]string{"de"}, ""},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		got, ok := Negotiate(test.requested, available)
+		if got != test.expected || ok != (test.expected != "") {
+			t.Errorf("%q: expected %q, got %q (%v).", test.requested, test.expected, got, ok)
+		}
+	}
+}
+
+func TestNegotiateFallback(t *testing.T) {
+	available := []string{"zh-Hans", "zh-Hant", "zh-Hant-HK", "sr-Latn", "sr-Cyrl-ME", "pt-PT", "pt-BR"}
+	tests := []struct {
+		requested string
+		expected  string
+	}{
+		{"zh-TW", "zh-Hant"},
+		{"zh-HK", "zh-Hant-HK"},
+		{"zh-MO", "zh-Hant"},
+		{"zh-CN", "zh-Hans"},
+		{"zh_SG", "zh-Hans"},
+		{"zh-Hant-TW", "zh-Hant"},
+		{"zh", "zh-Hans"},
+		{"sr-ME", "sr-Cyrl-ME"},
+		{"sr-Latn-ME", "sr-Latn"},
+		{"pt", "pt-BR"},
+	}
+	// The result doesn't depend on the order of the available locales.
+	reversed := make([]string, len(available))
+	for i, name := range available {
+		reversed[len(available)-1-i] = name
+	}
+	for _, test := range tests {
+		for _, a := range [][]string{available, reversed} {
+			if got, _ := Negotiate([]string{test.requested}, a); got != test.expected {
+				t.Errorf("%q in %q: expected %q, got %q.", test.requested, a, test.expected, got)
+			}
+		}
+	}
+}
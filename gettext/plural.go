@@ -0,0 +1,386 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxPluralDepth limits the nesting of plural expressions, so that hostile
+// catalog headers can't exhaust the stack.
+const maxPluralDepth = 64
+
+// DefaultPluralRule is the rule used by GNU gettext when a catalog doesn't
+// declare its plural forms. It is also the rule of English and many other
+// languages.
+var DefaultPluralRule = MustParsePluralForms("nplurals=2; plural=(n != 1);")
+
+// PluralRule selects the plural form to use for a given number.
+//
+// A rule is compiled from the Plural-Forms value of a catalog header,
+// and is safe for concurrent use.
+type PluralRule struct {
+	NPlurals int         // number of plural forms
+	expr     *pluralNode // plural expression
+	src      string      // Plural-Forms value
+}
+
+// ParsePluralForms compiles a Plural-Forms header value, such as:
+//
+//	nplurals=2; plural=(n != 1);
+//
+// The plural expression is a subset of C: the variable n, decimal
+// constants, parentheses and the operators ! * / % + - < > <= >= == !=
+// && || and ?:. Constant results of the expression must be valid indices,
+// between 0 and nplurals-1.
+func ParsePluralForms(s string) (*PluralRule, error) {
+	r := &PluralRule{src: s}
+	var plural string
+	for _, field := range strings.Split(s, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		idx := strings.Index(field, "=")
+		if idx == -1 {
+			return nil, pluralError(s, "expected name=value, got %q", field)
+		}
+		name, value := strings.TrimSpace(field[:idx]), strings.TrimSpace(field[idx+1:])
+		switch name {
+		case "nplurals":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 256 {
+				return nil, pluralError(s, "invalid nplurals %q", value)
+			}
+			r.NPlurals = n
+		case "plural":
+			plural = value
+		default:
+			return nil, pluralError(s, "unknown field %q", name)
+		}
+	}
+	if r.NPlurals == 0 {
+		return nil, pluralError(s, "missing nplurals")
+	}
+	if plural == "" {
+		return nil, pluralError(s, "missing plural expression")
+	}
+	p := &pluralParser{src: plural}
+	expr, err := p.parse()
+	if err != nil {
+		return nil, pluralError(s, "%s", err)
+	}
+	if err := checkPluralIndices(expr, r.NPlurals); err != nil {
+		return nil, pluralError(s, "%s", err)
+	}
+	r.expr = expr
+	return r, nil
+}
+
+// MustParsePluralForms is like ParsePluralForms but panics if the value
+// can't be compiled.
+func MustParsePluralForms(s string) *PluralRule {
+	r, err := ParsePluralForms(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Index returns the index of the plural form to use for n, between 0 and
+// NPlurals-1.
+//
+// Negative numbers use the form of their absolute value. This is a choice
+// of this package: GNU ngettext takes an unsigned long, so it would select
+// the form of a huge number instead. If the expression divides by zero or
+// results in an index out of range, the first form is used.
+func (r *PluralRule) Index(n int) int {
+	v := uint64(n)
+	if n < 0 {
+		v = uint64(-n)
+	}
+	idx, ok := r.expr.eval(v)
+	if !ok || idx >= uint64(r.NPlurals) {
+		return 0
+	}
+	return int(idx)
+}
+
+// String returns the Plural-Forms value the rule was compiled from.
+func (r *PluralRule) String() string {
+	return r.src
+}
+
+func pluralError(src, format string, args ...interface{}) error {
+	return fmt.Errorf("Invalid Plural-Forms %q: %s.", src, fmt.Sprintf(format, args...))
+}
+
+// ----------------------------------------------------------------------------
+
+// pluralNode is a node of a plural expression tree.
+type pluralNode struct {
+	op   string        // operator, "n" or "" for a constant
+	val  uint64        // constant value
+	args []*pluralNode // operands
+}
+
+// eval evaluates the expression for n. It returns false on division by zero.
+func (e *pluralNode) eval(n uint64) (uint64, bool) {
+	switch e.op {
+	case "":
+		return e.val, true
+	case "n":
+		return n, true
+	case "?:":
+		c, ok := e.args[0].eval(n)
+		if !ok {
+			return 0, false
+		}
+		if c != 0 {
+			return e.args[1].eval(n)
+		}
+		return e.args[2].eval(n)
+	case "!":
+		v, ok := e.args[0].eval(n)
+		return boolValue(v == 0), ok
+	}
+	x, ok := e.args[0].eval(n)
+	if !ok {
+		return 0, false
+	}
+	// Short-circuit evaluation, as in C.
+	switch {
+	case e.op == "&&" && x == 0:
+		return 0, true
+	case e.op == "||" && x != 0:
+		return 1, true
+	}
+	y, ok := e.args[1].eval(n)
+	if !ok {
+		return 0, false
+	}
+	switch e.op {
+	case "*":
+		return x * y, true
+	case "/":
+		if y == 0 {
+			return 0, false
+		}
+		return x / y, true
+	case "%":
+		if y == 0 {
+			return 0, false
+		}
+		return x % y, true
+	case "+":
+		return x + y, true
+	case "-":
+		return x - y, true
+	case "<":
+		return boolValue(x < y), true
+	case ">":
+		return boolValue(x > y), true
+	case "<=":
+		return boolValue(x <= y), true
+	case ">=":
+		return boolValue(x >= y), true
+	case "==":
+		return boolValue(x == y), true
+	case "!=":
+		return boolValue(x != y), true
+	case "&&", "||":
+		return boolValue(y != 0), true
+	}
+	panic("unreachable")
+}
+
+func boolValue(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// checkPluralIndices checks that the constant results of an expression are
+// valid plural indices.
+func checkPluralIndices(e *pluralNode, nplurals int) error {
+	switch e.op {
+	case "":
+		if e.val >= uint64(nplurals) {
+			return fmt.Errorf("plural index %d out of range for nplurals=%d", e.val, nplurals)
+		}
+	case "?:":
+		if err := checkPluralIndices(e.args[1], nplurals); err != nil {
+			return err
+		}
+		return checkPluralIndices(e.args[2], nplurals)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+
+// Binary operators, from the lowest to the highest precedence.
+var pluralBinaryOps = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<=", ">=", "<", ">"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// pluralParser is a recursive descent parser for plural expressions.
+type pluralParser struct {
+	src   string // expression source
+	pos   int    // current position in src
+	depth int    // current nesting depth
+}
+
+// parse parses the whole expression.
+func (p *pluralParser) parse() (*pluralNode, error) {
+	e, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpaces(); p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return e, nil
+}
+
+// parseTernary parses a conditional expression, which is right associative.
+func (p *pluralParser) parseTernary() (*pluralNode, error) {
+	if p.depth++; p.depth > maxPluralDepth {
+		return nil, p.errorf("expression is too complex")
+	}
+	defer func() { p.depth-- }()
+	cond, err := p.parseBinary(0)
+	if err != nil || !p.accept("?") {
+		return cond, err
+	}
+	then, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept(":") {
+		return nil, p.errorf("expected ':'")
+	}
+	els, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	return &pluralNode{op: "?:", args: []*pluralNode{cond, then, els}}, nil
+}
+
+// parseBinary parses a left associative binary expression with operators
+// of the given precedence level or higher.
+func (p *pluralParser) parseBinary(level int) (*pluralNode, error) {
+	if level == len(pluralBinaryOps) {
+		return p.parseUnary()
+	}
+	x, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range pluralBinaryOps[level] {
+			if p.accept(o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return x, nil
+		}
+		y, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &pluralNode{op: op, args: []*pluralNode{x, y}}
+	}
+}
+
+// parseUnary parses a negation, a parenthesized expression, n or
+// a constant.
+func (p *pluralParser) parseUnary() (*pluralNode, error) {
+	switch {
+	case p.accept("!"):
+		if p.depth++; p.depth > maxPluralDepth {
+			return nil, p.errorf("expression is too complex")
+		}
+		defer func() { p.depth-- }()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &pluralNode{op: "!", args: []*pluralNode{x}}, nil
+	case p.accept("("):
+		x, err := p.parseTernary()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("expected ')'")
+		}
+		return x, nil
+	case p.accept("n"):
+		return &pluralNode{op: "n"}, nil
+	}
+	start := p.pos
+	for p.pos < len(p.src) && '0' <= p.src[p.pos] && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	if start == p.pos {
+		if p.pos == len(p.src) {
+			return nil, p.errorf("unexpected end of expression")
+		}
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	v, err := strconv.ParseUint(p.src[start:p.pos], 10, 64)
+	if err != nil {
+		return nil, p.errorf("invalid number %q", p.src[start:p.pos])
+	}
+	return &pluralNode{val: v}, nil
+}
+
+// accept consumes the given token, if it is next in the input.
+func (p *pluralParser) accept(token string) bool {
+	p.skipSpaces()
+	if !strings.HasPrefix(p.src[p.pos:], token) {
+		return false
+	}
+	// Don't take "<" from "<=", "!" from "!=", or "n" from an identifier.
+	rest := p.src[p.pos+len(token):]
+	switch token {
+	case "<", ">", "!":
+		if strings.HasPrefix(rest, "=") {
+			return false
+		}
+	case "n":
+		if len(rest) > 0 && isIdentChar(rest[0]) {
+			return false
+		}
+	}
+	p.pos += len(token)
+	return true
+}
+
+func (p *pluralParser) skipSpaces() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\r\n", p.src[p.pos]) != -1 {
+		p.pos++
+	}
+}
+
+func (p *pluralParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at position %d", fmt.Sprintf(format, args...), p.pos)
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
@@ -0,0 +1,77 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPluralRule(t *testing.T) {
+	tests := []struct {
+		forms   string
+		indices []int // indices for n = 0, 1, 2...
+	}{
+		// English
+		{"nplurals=2; plural=(n != 1);", []int{1, 0, 1, 1}},
+		// Japanese
+		{"nplurals=1; plural=0;", []int{0, 0, 0}},
+		// French
+		{"nplurals=2; plural=n>1;", []int{0, 0, 1, 1}},
+		// Polish
+		{"nplurals=3; plural=(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+			[]int{2, 0, 1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1, 2}},
+		// Russian
+		{"nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+			[]int{2, 0, 1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 0, 1}},
+		// Arabic
+		{"nplurals=6; plural=n==0 ? 0 : n==1 ? 1 : n==2 ? 2 : n%100>=3 && n%100<=10 ? 3 : n%100>=11 ? 4 : 5;",
+			[]int{0, 1, 2, 3, 3, 3, 3, 3, 3, 3, 3, 4}},
+		// Operators and precedence.
+		{"nplurals=4; plural=!(n - 1) + 2 * !!(n / 4) * (n % 4 == 0);", []int{0, 1, 0, 0, 2}},
+		// Division by zero falls back to the first form.
+		{"nplurals=2; plural=1 / (n - 3);", []int{0, 0, 0, 0, 1}},
+	}
+	for _, test := range tests {
+		r, err := ParsePluralForms(test.forms)
+		if err != nil {
+			t.Errorf("%s: %v", test.forms, err)
+			continue
+		}
+		for n, idx := range test.indices {
+			if got := r.Index(n); got != idx {
+				t.Errorf("%s: expected %d for n=%d, got %d.", test.forms, idx, n, got)
+			}
+		}
+	}
+	if idx := DefaultPluralRule.Index(-1); idx != 0 {
+		t.Errorf("Expected 0 for n=-1, got %d.", idx)
+	}
+}
+
+func TestPluralRuleErrors(t *testing.T) {
+	tests := []struct {
+		forms string
+		err   string
+	}{
+		{"plural=n != 1;", "missing nplurals"},
+		{"nplurals=2;", "missing plural expression"},
+		{"nplurals=0; plural=0;", "invalid nplurals"},
+		{"nplurals=2; plural=n ? 1 : 2;", "out of range"},
+		{"nplurals=2; plural=(n != 1;", "expected ')'"},
+		{"nplurals=2; plural=n ? 1;", "expected ':'"},
+		{"nplurals=2; plural=x;", "unexpected \"x\""},
+		{"nplurals=2; plural=n &;", "unexpected \"&\""},
+		{"nplurals=2; plural=n !=;", "unexpected end"},
+		{"nplurals=2; plural=" + strings.Repeat("(", 100) + "n" + strings.Repeat(")", 100), "too complex"},
+		{"nplurals=2; plural=n; foo=1", "unknown field"},
+	}
+	for _, test := range tests {
+		_, err := ParsePluralForms(test.forms)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error containing %q, got %v.", test.forms, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,656 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ReadPo reads a PO file from r and returns a messages iterator.
+//
+// The whole file is parsed on the first call to Size() or Next(). If the
+// file is malformed, Next() returns a *PoSyntaxError.
+func ReadPo(r io.Reader) Iterator {
+	return &poReader{reader: r}
+}
+
+// WritePo writes a PO file to w using the provided messages iterator.
+//
+// Messages are written using the same layout as GNU gettext tools: strings
+// and references are wrapped at 79 columns, so a PO file written by
+// msgcat, msgmerge or Poedit is left unchanged by ReadPo followed by WritePo.
+func WritePo(w io.Writer, iter Iterator) error {
+	writer := &poWriter{
+		writer: bufio.NewWriter(w),
+		iter:   iter,
+	}
+	if err := writer.writeAll(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// PoSyntaxError is returned when a PO file can't be parsed.
+type PoSyntaxError struct {
+	Line int    // line number, starting at 1
+	Msg  string // description of the problem
+}
+
+func (e *PoSyntaxError) Error() string {
+	return fmt.Sprintf("PO syntax error at line %d: %s.", e.Line, e.Msg)
+}
+
+// ----------------------------------------------------------------------------
+
+var (
+	// Line prefixes for comments and keywords.
+	poObsolete     = []byte("#~")
+	poPrevious     = []byte("#|")
+	poExtracted    = []byte("#.")
+	poReference    = []byte("#:")
+	poFlag         = []byte("#,")
+	poComment      = []byte("#")
+	poMsgctxt      = []byte("msgctxt")
+	poMsgid        = []byte("msgid")
+	poMsgidPlural  = []byte("msgid_plural")
+	poMsgstr       = []byte("msgstr")
+	poMsgstrPlural = []byte("msgstr[")
+	utf8BOM        = []byte("\xef\xbb\xbf")
+)
+
+// poReader reads a PO file.
+type poReader struct {
+	reader io.Reader  // stream reader
+	msgs   []*Message // parsed messages
+	parsed bool       // whether the stream was already parsed
+	pos    int        // iterator position
+	err    error      // iterator error
+}
+
+func (r *poReader) init() {
+	if !r.parsed {
+		p := &poParser{}
+		if err := p.parse(r.reader); err != nil {
+			r.err = err
+		} else {
+			r.msgs = p.msgs
+		}
+		r.parsed = true
+	}
+}
+
+// Size returns the amount of messages provided by the iterator.
+func (r *poReader) Size() int {
+	r.init()
+	return len(r.msgs)
+}
+
+// Next returns the next message. At the end of the iteration,
+// io.EOF is returned as the error.
+func (r *poReader) Next() (*Message, error) {
+	r.init()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.pos >= len(r.msgs) {
+		r.err = io.EOF
+		return nil, r.err
+	}
+	msg := r.msgs[r.pos]
+	r.pos += 1
+	return msg, nil
+}
+
+// ----------------------------------------------------------------------------
+
+// poParser parses the PO grammar, one line at a time.
+type poParser struct {
+	msgs   []*Message // complete messages
+	msg    *Message   // message being parsed
+	hasStr bool       // whether msg already has a msgstr
+	target *[]byte    // string that continuation lines append to
+	line   int        // current line number
+}
+
+// parse reads all messages from r.
+func (p *poParser) parse(r io.Reader) error {
+	// Lines are read with ReadBytes, which has no line length limit.
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			p.line++
+			if p.line == 1 {
+				line = bytes.TrimPrefix(line, utf8BOM)
+			}
+			if err := p.parseLine(bytes.TrimSpace(line)); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return p.flush()
+}
+
+// parseLine parses a single line with leading and trailing spaces removed.
+func (p *poParser) parseLine(line []byte) error {
+	if len(line) == 0 {
+		// Blank lines only terminate a complete message.
+		if p.hasStr {
+			return p.flush()
+		}
+		return nil
+	}
+	if bytes.HasPrefix(line, poObsolete) {
+		line = bytes.TrimSpace(line[len(poObsolete):])
+		if len(line) == 0 {
+			return nil
+		}
+		if err := p.begin(line); err != nil {
+			return err
+		}
+		p.meta().Obsolete = true
+		if bytes.HasPrefix(line, []byte("|")) {
+			return p.parsePrevious(bytes.TrimSpace(line[1:]))
+		}
+		return p.parseKeyword(line)
+	}
+	if line[0] == '#' {
+		if err := p.begin(line); err != nil {
+			return err
+		}
+		return p.parseComment(line)
+	}
+	return p.parseKeyword(line)
+}
+
+// begin finishes the previous message if the given comment or keyword line
+// starts a new one.
+func (p *poParser) begin(line []byte) error {
+	if p.hasStr && !bytes.HasPrefix(line, poMsgstr) && line[0] != '"' {
+		return p.flush()
+	}
+	return nil
+}
+
+// parseComment parses a comment line.
+func (p *poParser) parseComment(line []byte) error {
+	switch {
+	case bytes.HasPrefix(line, poPrevious):
+		return p.parsePrevious(bytes.TrimSpace(line[len(poPrevious):]))
+	case bytes.HasPrefix(line, poExtracted):
+		m := p.meta()
+		m.ExtractedComments = append(m.ExtractedComments,
+			commentText(line[len(poExtracted):]))
+	case bytes.HasPrefix(line, poReference):
+		m := p.meta()
+		for _, ref := range bytes.Fields(line[len(poReference):]) {
+			m.References = append(m.References, ref)
+		}
+	case bytes.HasPrefix(line, poFlag):
+		m := p.meta()
+		for _, flag := range bytes.Split(line[len(poFlag):], []byte(",")) {
+			if flag = bytes.TrimSpace(flag); len(flag) > 0 {
+				m.Flags = append(m.Flags, flag)
+			}
+		}
+	default:
+		m := p.meta()
+		m.TranslatorComments = append(m.TranslatorComments,
+			commentText(line[len(poComment):]))
+	}
+	p.target = nil
+	return nil
+}
+
+// parsePrevious parses the contents of a "#|" comment line.
+func (p *poParser) parsePrevious(line []byte) error {
+	if len(line) > 0 && line[0] == '"' {
+		return p.appendString(line)
+	}
+	keyword, value := splitKeyword(line)
+	m := p.meta()
+	switch {
+	case bytes.Equal(keyword, poMsgctxt):
+		p.target = &m.PrevCtxt
+	case bytes.Equal(keyword, poMsgid):
+		p.target = &m.PrevId
+	case bytes.Equal(keyword, poMsgidPlural):
+		p.target = &m.PrevIdPlural
+	default:
+		return p.errorf("unexpected previous keyword %q", keyword)
+	}
+	if *p.target != nil {
+		return p.errorf("duplicate previous %s", keyword)
+	}
+	*p.target = []byte{}
+	return p.appendString(value)
+}
+
+// parseKeyword parses a keyword or string continuation line.
+func (p *poParser) parseKeyword(line []byte) error {
+	if line[0] == '"' {
+		return p.appendString(line)
+	}
+	keyword, value := splitKeyword(line)
+	switch {
+	case bytes.Equal(keyword, poMsgctxt):
+		if p.hasStr {
+			if err := p.flush(); err != nil {
+				return err
+			}
+		}
+		msg := p.message()
+		if msg.Ctxt != nil || msg.Id != nil {
+			return p.errorf("unexpected msgctxt")
+		}
+		msg.Ctxt = []byte{}
+		p.target = &msg.Ctxt
+	case bytes.Equal(keyword, poMsgid):
+		if p.hasStr {
+			if err := p.flush(); err != nil {
+				return err
+			}
+		}
+		msg := p.message()
+		if msg.Id != nil {
+			return p.errorf("missing msgstr")
+		}
+		msg.Id = []byte{}
+		p.target = &msg.Id
+	case bytes.Equal(keyword, poMsgidPlural):
+		if p.msg == nil || p.msg.Id == nil || p.msg.IdPlural != nil || p.hasStr {
+			return p.errorf("unexpected msgid_plural")
+		}
+		p.msg.IdPlural = []byte{}
+		p.target = &p.msg.IdPlural
+	case bytes.Equal(keyword, poMsgstr):
+		if p.msg == nil || p.msg.Id == nil || p.hasStr {
+			return p.errorf("unexpected msgstr")
+		}
+		if p.msg.IdPlural != nil {
+			return p.errorf("expected msgstr[0] for a plural message")
+		}
+		p.msg.Str = []byte{}
+		p.target = &p.msg.Str
+		p.hasStr = true
+	case bytes.HasPrefix(keyword, poMsgstrPlural):
+		if p.msg == nil || p.msg.IdPlural == nil {
+			return p.errorf("unexpected %s", keyword)
+		}
+		idx := keyword[len(poMsgstrPlural):]
+		if len(idx) < 2 || idx[len(idx)-1] != ']' {
+			return p.errorf("invalid keyword %q", keyword)
+		}
+		n, err := strconv.Atoi(string(idx[:len(idx)-1]))
+		if err != nil || n != len(p.msg.StrPlural) {
+			return p.errorf("unexpected %s", keyword)
+		}
+		p.msg.StrPlural = append(p.msg.StrPlural, []byte{})
+		p.target = &p.msg.StrPlural[n]
+		p.hasStr = true
+	default:
+		return p.errorf("unknown keyword %q", keyword)
+	}
+	return p.appendString(value)
+}
+
+// appendString unquotes s and appends it to the current target.
+func (p *poParser) appendString(s []byte) error {
+	if p.target == nil {
+		return p.errorf("unexpected string")
+	}
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return p.errorf("invalid quoted string")
+	}
+	b, err := unquote(*p.target, s[1:len(s)-1])
+	if err != nil {
+		return p.errorf("%s", err)
+	}
+	*p.target = b
+	return nil
+}
+
+// flush adds the message being parsed to the list of complete messages.
+func (p *poParser) flush() error {
+	msg := p.msg
+	p.msg, p.hasStr, p.target = nil, false, nil
+	if msg == nil {
+		return nil
+	}
+	switch {
+	case msg.Id == nil:
+		// Dangling comments at the end of the file are discarded.
+		if msg.Ctxt == nil {
+			return nil
+		}
+		return p.errorf("missing msgid")
+	case msg.Str == nil && msg.StrPlural == nil:
+		return p.errorf("missing msgstr")
+	}
+	p.msgs = append(p.msgs, msg)
+	return nil
+}
+
+// message returns the message being parsed, creating it if needed.
+func (p *poParser) message() *Message {
+	if p.msg == nil {
+		p.msg = &Message{}
+	}
+	return p.msg
+}
+
+// meta returns the metadata of the message being parsed, creating it
+// if needed.
+func (p *poParser) meta() *MessageMeta {
+	msg := p.message()
+	if msg.Meta == nil {
+		msg.Meta = &MessageMeta{}
+	}
+	return msg.Meta
+}
+
+func (p *poParser) errorf(format string, args ...interface{}) error {
+	return &PoSyntaxError{Line: p.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// ----------------------------------------------------------------------------
+
+// poLineWidth is the maximum width of lines written to PO files.
+const poLineWidth = 79
+
+// poWriter writes a PO file.
+type poWriter struct {
+	writer *bufio.Writer // buffered stream writer
+	iter   Iterator      // messages to write
+	prefix string        // line prefix of the message being written
+}
+
+// writeAll writes the whole PO file.
+func (w *poWriter) writeAll() error {
+	for i := 0; ; i++ {
+		msg, err := w.iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			w.writer.WriteString("\n")
+		}
+		w.writeMessage(msg)
+	}
+	return w.writer.Flush()
+}
+
+// writeMessage writes a single PO entry.
+func (w *poWriter) writeMessage(msg *Message) {
+	w.prefix = ""
+	if m := msg.Meta; m != nil {
+		for _, c := range m.TranslatorComments {
+			w.writeComment("#", c)
+		}
+		for _, c := range m.ExtractedComments {
+			w.writeComment("#.", c)
+		}
+		w.writeReferences(m.References)
+		if len(m.Flags) > 0 {
+			w.writer.WriteString("#,")
+			for i, flag := range m.Flags {
+				if i > 0 {
+					w.writer.WriteByte(',')
+				}
+				w.writer.WriteByte(' ')
+				w.writer.Write(flag)
+			}
+			w.writer.WriteString("\n")
+		}
+		if m.Obsolete {
+			w.prefix = "#~ "
+		}
+		prefix := w.prefix
+		w.prefix = "#| "
+		if m.Obsolete {
+			w.prefix = "#~| "
+		}
+		if m.PrevCtxt != nil {
+			w.writeString("msgctxt", m.PrevCtxt)
+		}
+		if m.PrevId != nil {
+			w.writeString("msgid", m.PrevId)
+		}
+		if m.PrevIdPlural != nil {
+			w.writeString("msgid_plural", m.PrevIdPlural)
+		}
+		w.prefix = prefix
+	}
+	if msg.Ctxt != nil {
+		w.writeString("msgctxt", msg.Ctxt)
+	}
+	w.writeString("msgid", msg.Id)
+	if msg.IdPlural == nil {
+		w.writeString("msgstr", msg.Str)
+		return
+	}
+	w.writeString("msgid_plural", msg.IdPlural)
+	for i, str := range msg.StrPlural {
+		w.writeString(fmt.Sprintf("msgstr[%d]", i), str)
+	}
+}
+
+// writeComment writes a comment line.
+func (w *poWriter) writeComment(prefix string, text []byte) {
+	w.writer.WriteString(prefix)
+	if len(text) > 0 {
+		w.writer.WriteByte(' ')
+		w.writer.Write(text)
+	}
+	w.writer.WriteString("\n")
+}
+
+// writeReferences writes the "#:" comment lines, packing as many
+// references as possible in each line.
+func (w *poWriter) writeReferences(refs [][]byte) {
+	width := 0
+	for _, ref := range refs {
+		refWidth := utf8.RuneCount(ref) + 1
+		if width > 0 && width+refWidth > poLineWidth {
+			w.writer.WriteString("\n")
+			width = 0
+		}
+		if width == 0 {
+			w.writer.WriteString("#:")
+			width = 2
+		}
+		w.writer.WriteByte(' ')
+		w.writer.Write(ref)
+		width += refWidth
+	}
+	if width > 0 {
+		w.writer.WriteString("\n")
+	}
+}
+
+// writeString writes a keyword followed by a quoted string, splitting it
+// in several lines if it contains newlines or is too long.
+func (w *poWriter) writeString(keyword string, s []byte) {
+	var lines []string
+	for {
+		idx := bytes.IndexByte(s, '\n')
+		if idx == -1 || idx == len(s)-1 {
+			break
+		}
+		lines = append(lines, quote(s[:idx+1]))
+		s = s[idx+1:]
+	}
+	lines = append(lines, quote(s))
+	head := w.prefix + keyword + " "
+	if len(lines) == 1 && utf8.RuneCountInString(head)+utf8.RuneCountInString(lines[0])+2 <= poLineWidth {
+		w.writer.WriteString(head + `"` + lines[0] + `"` + "\n")
+		return
+	}
+	w.writer.WriteString(head + `""` + "\n")
+	width := poLineWidth - utf8.RuneCountInString(w.prefix) - 2
+	for _, line := range lines {
+		for _, l := range wrapLine(line, width) {
+			w.writer.WriteString(w.prefix + `"` + l + `"` + "\n")
+		}
+	}
+}
+
+// wrapLine splits an escaped string after spaces so that each part fits in
+// the given width, when possible.
+func wrapLine(s string, width int) []string {
+	var lines []string
+	start, end, n := 0, 0, 0
+	for end < len(s) {
+		next := len(s)
+		if idx := strings.IndexByte(s[end:], ' '); idx != -1 {
+			next = end + idx + 1
+		}
+		size := utf8.RuneCountInString(s[end:next])
+		if n > 0 && n+size > width {
+			lines = append(lines, s[start:end])
+			start, n = end, 0
+		}
+		n += size
+		end = next
+	}
+	return append(lines, s[start:])
+}
+
+// quote escapes a string to be written in a PO file.
+func quote(s []byte) string {
+	b := make([]byte, 0, len(s))
+	for _, c := range s {
+		switch c {
+		case '\\', '"':
+			b = append(b, '\\', c)
+		case '\a':
+			b = append(b, '\\', 'a')
+		case '\b':
+			b = append(b, '\\', 'b')
+		case '\f':
+			b = append(b, '\\', 'f')
+		case '\n':
+			b = append(b, '\\', 'n')
+		case '\r':
+			b = append(b, '\\', 'r')
+		case '\t':
+			b = append(b, '\\', 't')
+		case '\v':
+			b = append(b, '\\', 'v')
+		default:
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
+
+// splitKeyword splits a line into a keyword and the remaining value.
+func splitKeyword(line []byte) (keyword, value []byte) {
+	if idx := bytes.IndexAny(line, " \t\""); idx != -1 {
+		return line[:idx], bytes.TrimSpace(line[idx:])
+	}
+	return line, nil
+}
+
+// commentText returns the text of a comment, without the separator space.
+func commentText(b []byte) []byte {
+	if len(b) > 0 && b[0] == ' ' {
+		b = b[1:]
+	}
+	return append([]byte{}, b...)
+}
+
+// unquote appends the unescaped contents of a PO string to dst.
+func unquote(dst, s []byte) ([]byte, error) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' {
+			return nil, fmt.Errorf("unescaped quote")
+		}
+		if c != '\\' {
+			dst = append(dst, c)
+			continue
+		}
+		i++
+		if i == len(s) {
+			return nil, fmt.Errorf("unterminated escape sequence")
+		}
+		switch c = s[i]; c {
+		case 'a':
+			dst = append(dst, '\a')
+		case 'b':
+			dst = append(dst, '\b')
+		case 'f':
+			dst = append(dst, '\f')
+		case 'n':
+			dst = append(dst, '\n')
+		case 'r':
+			dst = append(dst, '\r')
+		case 't':
+			dst = append(dst, '\t')
+		case 'v':
+			dst = append(dst, '\v')
+		case '\\', '"', '\'', '?':
+			dst = append(dst, c)
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			// Up to three octal digits.
+			v, j := 0, i
+			for ; j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7'; j++ {
+				v = v*8 + int(s[j]-'0')
+			}
+			if v > 0xff {
+				return nil, fmt.Errorf("octal escape out of range")
+			}
+			dst = append(dst, byte(v))
+			i = j - 1
+		case 'x':
+			// One or more hex digits.
+			v, j := 0, i+1
+			for ; j < len(s) && isHexDigit(s[j]); j++ {
+				v = v*16 + hexValue(s[j])
+				if v > 0xff {
+					return nil, fmt.Errorf("hex escape out of range")
+				}
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("invalid hex escape")
+			}
+			dst = append(dst, byte(v))
+			i = j - 1
+		default:
+			return nil, fmt.Errorf("invalid escape sequence \\%c", c)
+		}
+	}
+	return dst, nil
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func hexValue(c byte) int {
+	switch {
+	case c >= 'a':
+		return int(c-'a') + 10
+	case c >= 'A':
+		return int(c-'A') + 10
+	}
+	return int(c - '0')
+}
@@ -0,0 +1,231 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var poData = `# Spanish translations for the test suite.
+msgid ""
+msgstr ""
+"Project-Id-Version: 2.0\n"
+"Language: es\n"
+"Content-Type: text/plain; charset=UTF-8\n"
+"Plural-Forms: nplurals=2; plural=n != 1;\n"
+
+#  indented translator comment
+#. TRANSLATORS: shown in the title bar
+#: main.go:12 main.go:40
+#: view.go:7
+#, fuzzy, go-format
+#| msgctxt "old"
+#| msgid "Old %s"
+msgctxt "menu"
+msgid "File %s"
+msgstr ""
+"Archivo "
+"%s"
+
+msgid "There is %d file"
+msgid_plural "There are %d files"
+msgstr[0] "Hay %d fichero"
+msgstr[1] "Hay %d ficheros"
+
+msgid "Tab\there, \"quoted\"\n"
+msgstr "Tab\tahí, \"citado\"\n\101\x42"
+
+#~ msgid "Gone"
+#~ msgstr ""
+#~ "Ido"
+`
+
+func TestReadPo(t *testing.T) {
+	iter := ReadPo(strings.NewReader(poData))
+	if size := iter.Size(); size != 5 {
+		t.Fatalf("Expected 5 messages, got %d.", size)
+	}
+	var msgs []*Message
+	for {
+		msg, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	header := msgs[0]
+	if len(header.Id) != 0 || !strings.HasPrefix(string(header.Str), "Project-Id-Version: 2.0\nLanguage: es\n") {
+		t.Errorf("Unexpected header %q.", header.Str)
+	}
+	if header.Meta == nil || string(header.Meta.TranslatorComments[0]) != "Spanish translations for the test suite." {
+		t.Errorf("Unexpected header comments.")
+	}
+
+	ctxt := msgs[1]
+	expected := &Message{
+		Ctxt: []byte("menu"),
+		Id:   []byte("File %s"),
+		Str:  []byte("Archivo %s"),
+		Meta: &MessageMeta{
+			TranslatorComments: [][]byte{[]byte(" indented translator comment")},
+			ExtractedComments:  [][]byte{[]byte("TRANSLATORS: shown in the title bar")},
+			References:         [][]byte{[]byte("main.go:12"), []byte("main.go:40"), []byte("view.go:7")},
+			Flags:              [][]byte{[]byte("fuzzy"), []byte("go-format")},
+			PrevCtxt:           []byte("old"),
+			PrevId:             []byte("Old %s"),
+		},
+	}
+	if !reflect.DeepEqual(ctxt, expected) {
+		t.Errorf("Expected %+v, got %+v.", expected, ctxt)
+	}
+
+	plural := msgs[2]
+	if string(plural.IdPlural) != "There are %d files" || len(plural.StrPlural) != 2 ||
+		string(plural.StrPlural[1]) != "Hay %d ficheros" || plural.Str != nil {
+		t.Errorf("Unexpected plural message %+v.", plural)
+	}
+
+	escaped := msgs[3]
+	if string(escaped.Id) != "Tab\there, \"quoted\"\n" || string(escaped.Str) != "Tab\tah\xc3\xad, \"citado\"\nAB" {
+		t.Errorf("Unexpected escaped message %q, %q.", escaped.Id, escaped.Str)
+	}
+
+	obsolete := msgs[4]
+	if string(obsolete.Id) != "Gone" || string(obsolete.Str) != "Ido" || !obsolete.Meta.Obsolete {
+		t.Errorf("Unexpected obsolete message %+v.", obsolete)
+	}
+}
+
+func TestReadPoLongLines(t *testing.T) {
+	long := strings.Repeat("x", 200000)
+	data := "msgid \"a\"\r\nmsgstr \"" + long + "\""
+	msg, err := ReadPo(strings.NewReader(data)).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Str) != long {
+		t.Errorf("Expected a msgstr of %d bytes, got %d.", len(long), len(msg.Str))
+	}
+}
+
+func TestReadPoErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		line int
+	}{
+		{"msgid \"a\"\nmsgstr \"b\nmsgid \"c\"\n", 2},
+		{"msgid \"a\"\n\nmsgid \"b\"\nmsgstr \"\"\n", 3},
+		{"msgid \"a\"\nmsgstr \"b\"\nmsgstr \"c\"\n", 3},
+		{"msgid \"a\"\nmsgid_plural \"b\"\nmsgstr[1] \"c\"\n", 3},
+		{"msgid \"a\"\nmsgstr \"\\q\"\n", 2},
+		{"# comment\nmsgstr \"a\"\n", 2},
+		{"msgid \"a\"\nmsgtxt \"b\"\n", 2},
+		{"msgid \"a\"\n", 1},
+	}
+	for _, test := range tests {
+		_, err := ReadPo(strings.NewReader(test.data)).Next()
+		if e, ok := err.(*PoSyntaxError); !ok || e.Line != test.line {
+			t.Errorf("Expected syntax error at line %d for %q, got %v.", test.line, test.data, err)
+		}
+	}
+}
+
+func TestCatalogReadPo(t *testing.T) {
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(poData)); err != nil {
+		t.Fatal(err)
+	}
+	if lang := c.Header.Get("Language"); lang != "es" {
+		t.Errorf("Expected language %q, got %q.", "es", lang)
+	}
+	if s := c.Singular("Gone"); s != "Gone" {
+		t.Errorf("Obsolete message should not be translated, got %q.", s)
+	}
+}
+
+var poRoundTripData = `# Translator comment.
+#
+msgid ""
+msgstr ""
+"Project-Id-Version: 2.0\n"
+"Language: es\n"
+
+#. TRANSLATORS: shown in the title bar
+#: main.go:12 main.go:40 view.go:7
+#: a/very/long/path/to/some/file/in/the/tree.go:1234 b.go:1
+#, fuzzy, go-format
+#| msgctxt "old"
+#| msgid "Old %s"
+msgctxt "menu"
+msgid "File %s"
+msgstr "Archivo %s"
+
+msgid ""
+"This is a long message that does not fit in a single line, so it is wrapped "
+"at spaces."
+msgstr ""
+"Este es un mensaje largo que no cabe en una sola línea, así que se divide en "
+"los espacios.\n"
+"Second line\twith \"quotes\" and \\backslashes\\."
+
+msgid "There is %d file"
+msgid_plural "There are %d files"
+msgstr[0] "Hay %d fichero"
+msgstr[1] "Hay %d ficheros"
+
+# Obsolete comment.
+#~| msgid "Went"
+#~ msgid "Gone"
+#~ msgstr ""
+#~ "Ido\n"
+#~ "lejos"
+`
+
+func TestWritePo(t *testing.T) {
+	b := new(bytes.Buffer)
+	if err := WritePo(b, ReadPo(strings.NewReader(poRoundTripData))); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.String(); s != poRoundTripData {
+		t.Errorf("Expected:\n%s\nGot:\n%s", poRoundTripData, s)
+	}
+}
+
+func TestCatalogWritePo(t *testing.T) {
+	c := NewCatalog()
+	if err := c.ReadPo(strings.NewReader(poRoundTripData)); err != nil {
+		t.Fatal(err)
+	}
+	b := new(bytes.Buffer)
+	if err := WritePo(b, c.Iter()); err != nil {
+		t.Fatal(err)
+	}
+	c2 := NewCatalog()
+	if err := c2.ReadPo(b); err != nil {
+		t.Fatal(err)
+	}
+	i1, i2 := c.Iter(), c2.Iter()
+	if i1.Size() != i2.Size() {
+		t.Fatalf("Expected %d messages, got %d.", i1.Size(), i2.Size())
+	}
+	for {
+		m1, err := i1.Next()
+		if err == io.EOF {
+			break
+		}
+		m2, _ := i2.Next()
+		if !reflect.DeepEqual(m1.Meta, m2.Meta) {
+			t.Errorf("Expected %+v, got %+v.", m1.Meta, m2.Meta)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultReloadInterval is the time between checks used by Reloader when
+// Interval is not set.
+const DefaultReloadInterval = 10 * time.Second
+
+// NewReloader returns a reloader for the catalogs of b found under root,
+// checking them every interval once started.
+func NewReloader(b *Bundle, root string, interval time.Duration) *Reloader {
+	return &Reloader{
+		Bundle:   b,
+		Root:     root,
+		Interval: interval,
+	}
+}
+
+// Reloader reloads the catalogs of a bundle when their files change, so
+// that translations can be updated without restarting the program.
+//
+// Catalog files are found in the GNU directory layout under Root, as done
+// by Bundle.Load, and a file is changed when its modification time or size
+// changes. A changed file is read into a new catalog, which replaces the
+// one in the bundle only if the whole file is valid; otherwise the current
+// catalog is kept. Lookups in progress, and translators obtained from the
+// bundle before, keep using the catalogs they started with.
+//
+// Files should be replaced by renaming a complete file over them, so that
+// a file is never read while it is being written. Catalogs whose files are
+// removed are kept.
+type Reloader struct {
+	Bundle    *Bundle       // bundle that receives the catalogs
+	Root      string        // root of the GNU directory layout
+	Interval  time.Duration // time between checks, or DefaultReloadInterval
+	ErrorFunc func(error)   // called with the errors of periodic checks, or nil
+	mu        sync.Mutex    // serializes checks
+	files     map[string]fileStamp
+	runMu     sync.Mutex // guards stop and done
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// fileStamp is the state of a file used to detect changes.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Reload checks the catalog files once, and replaces the catalogs whose
+// files changed since the previous check. The first check loads all the
+// catalogs.
+//
+// Errors found reading files are returned in a MultiError, as
+// *os.PathError values with the file name; other files are still loaded.
+// A file that fails is checked again once it changes.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	files, err := catalogFiles(r.Root)
+	if err != nil {
+		return err
+	}
+	if r.files == nil {
+		r.files = map[string]fileStamp{}
+	}
+	var errs MultiError
+	for _, file := range files {
+		fi, err := os.Stat(file.path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		stamp := fileStamp{modTime: fi.ModTime(), size: fi.Size()}
+		if old, ok := r.files[file.path]; ok && old.modTime.Equal(stamp.modTime) && old.size == stamp.size {
+			continue
+		}
+		r.files[file.path] = stamp
+		// Unlike Bundle.Load, catalogs with invalid messages are rejected.
+		c, err := loadCatalog(file.path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		r.Bundle.AddCatalog(file.locale, file.domain, c)
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// Start checks the catalog files every Interval in a new goroutine, until
+// Stop is called. Errors are passed to ErrorFunc. Calling Start on a
+// started reloader does nothing.
+func (r *Reloader) Start() {
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+	if r.stop != nil {
+		return
+	}
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.run(interval, r.stop, r.done)
+}
+
+// Stop stops the checks started by Start, and waits for a check in
+// progress to finish.
+func (r *Reloader) Stop() {
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+	if r.stop == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+	r.stop, r.done = nil, nil
+}
+
+// run checks the catalog files every interval until stop is closed, and
+// then closes done.
+func (r *Reloader) run(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := r.Reload(); err != nil && r.ErrorFunc != nil {
+				r.ErrorFunc(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// touchBundleFile sets the modification time of a file under root, so that
+// changes are seen even if the file system has a coarse time resolution.
+func touchBundleFile(t *testing.T, root, name string, mtime time.Time) {
+	path := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	root, err := ioutil.TempDir("", "testReloader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	const name = "de/LC_MESSAGES/app.po"
+	mtime := time.Now().Add(-time.Hour)
+	writeBundleFile(t, root, name, []byte(germanPoData))
+	touchBundleFile(t, root, name, mtime)
+
+	b := NewBundle("app")
+	r := NewReloader(b, root, time.Hour)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	old := b.Catalog("de", "")
+	if got := b.Dgettext("de", "", "Open"); got != "Öffnen" {
+		t.Fatalf("Expected %q, got %q.", "Öffnen", got)
+	}
+	tr := b.Translator("", "de")
+
+	// Unchanged files are not read again.
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Catalog("de", "") != old {
+		t.Errorf("Expected the catalog to be kept.")
+	}
+
+	// Changed files replace their catalogs.
+	writeBundleFile(t, root, name, []byte(strings.Replace(germanPoData, "Öffnen", "Aufmachen", 1)))
+	touchBundleFile(t, root, name, mtime.Add(time.Minute))
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Dgettext("de", "", "Open"); got != "Aufmachen" {
+		t.Errorf("Expected %q, got %q.", "Aufmachen", got)
+	}
+	if got := tr.Singular("Open"); got != "Öffnen" {
+		t.Errorf("Expected the old translator to return %q, got %q.", "Öffnen", got)
+	}
+
+	// Invalid files keep the current catalog, and are reported once.
+	current := b.Catalog("de", "")
+	writeBundleFile(t, root, name, []byte(germanPoData+"\nmsgid \"Open\"\nmsgstr \"Offen\"\n"))
+	touchBundleFile(t, root, name, mtime.Add(2*time.Minute))
+	err = r.Reload()
+	if errs, ok := err.(MultiError); !ok || len(errs) != 1 {
+		t.Errorf("Expected a MultiError with one error, got %v.", err)
+	} else if e, ok := errs[0].(*os.PathError); !ok || e.Path != filepath.Join(root, filepath.FromSlash(name)) {
+		t.Errorf("Expected a path error, got %v.", errs[0])
+	}
+	if b.Catalog("de", "") != current {
+		t.Errorf("Expected the catalog to be kept.")
+	}
+	if err := r.Reload(); err != nil {
+		t.Errorf("Expected no error for an unchanged file, got %v.", err)
+	}
+
+	if err := NewReloader(b, filepath.Join(root, "missing"), time.Hour).Reload(); !os.IsNotExist(err) {
+		t.Errorf("Expected a not exist error, got %v.", err)
+	}
+}
+
+func TestReloaderStart(t *testing.T) {
+	root, err := ioutil.TempDir("", "testReloaderStart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	const name = "de/LC_MESSAGES/app.po"
+	writeBundleFile(t, root, name, []byte(germanPoData))
+
+	b := NewBundle("app")
+	r := NewReloader(b, root, time.Millisecond)
+	errs := make(chan error, 100)
+	r.ErrorFunc = func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	r.Start()
+	r.Start()
+	defer r.Stop()
+
+	// Lookups run while catalogs are replaced.
+	deadline := time.Now().Add(5 * time.Second)
+	for b.Dgettext("de", "", "Open") != "Öffnen" {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the catalog to be loaded.")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	writeBundleFile(t, root, name, []byte(germanPoData+"\nmsgid \"Open\"\nmsgstr \"Offen\"\n"))
+	touchBundleFile(t, root, name, time.Now().Add(time.Minute))
+	select {
+	case err := <-errs:
+		if _, ok := err.(MultiError); !ok {
+			t.Errorf("Expected a MultiError, got %v.", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an error for the invalid file.")
+	}
+	if got := b.Dgettext("de", "", "Open"); got != "Öffnen" {
+		t.Errorf("Expected %q, got %q.", "Öffnen", got)
+	}
+	r.Stop()
+	r.Stop()
+}
@@ -0,0 +1,136 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"bytes"
+	"strings"
+)
+
+// System-dependent strings are C format strings that use the <inttypes.h>
+// macros, such as "%<PRIu64> files". MO files store them in separate
+// tables, split in static parts and named segments that are expanded when
+// the file is read. The segments are expanded to their Go equivalents, so
+// that "%<PRIu64> files" is read as "%d files".
+
+// moSegmentsEnd marks the last segment of a system-dependent string.
+const moSegmentsEnd uint32 = 0xffffffff
+
+// moSysdepString is a system-dependent string, split in static parts and
+// segments: parts[0], segments[0], parts[1], ..., parts[len(segments)].
+type moSysdepString struct {
+	parts    [][]byte
+	segments []string
+}
+
+// splitSysdep splits a C format string in static parts and the names of
+// its system-dependent segments. Strings with no segments have a single
+// part.
+func splitSysdep(s []byte) moSysdepString {
+	var r moSysdepString
+	start := 0
+	addSegment := func(from, to int, name string) {
+		r.parts = append(r.parts, s[start:from])
+		r.segments = append(r.segments, name)
+		start = to
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '%' {
+			i++
+			continue
+		}
+		// Argument number, flags, width and precision. The "I" flag is
+		// a segment by itself.
+		for i++; i < len(s) && strings.IndexByte("0123456789$-+ #'.*I", s[i]) != -1; i++ {
+			if s[i] == 'I' {
+				addSegment(i, i+1, "I")
+			}
+		}
+		if i < len(s) && s[i] == '<' {
+			if end := bytes.IndexByte(s[i:], '>'); end != -1 {
+				name := string(s[i+1 : i+end])
+				if _, ok := sysdepSegmentValue(name); ok {
+					addSegment(i, i+end+1, name)
+					i += end
+				}
+			}
+		}
+	}
+	r.parts = append(r.parts, s[start:])
+	return r
+}
+
+// staticSize returns the size of the static parts, including a NUL char at
+// the end.
+func (s moSysdepString) staticSize() int {
+	size := 1
+	for _, part := range s.parts {
+		size += len(part)
+	}
+	return size
+}
+
+// static returns the static parts of the string, concatenated.
+func (s moSysdepString) static() []byte {
+	return bytes.Join(s.parts, nil)
+}
+
+// expand returns the string with its segments expanded to their Go
+// equivalents, as done when the string is read.
+func (s moSysdepString) expand() []byte {
+	var b []byte
+	for i, part := range s.parts {
+		b = append(b, part...)
+		if i < len(s.segments) {
+			value, _ := sysdepSegmentValue(s.segments[i])
+			b = append(b, value...)
+		}
+	}
+	return b
+}
+
+// descriptor returns the sysdep_string structure for the string, whose
+// static parts are stored at the given offset.
+func (s moSysdepString) descriptor(offset uint32, segmentIndex map[string]uint32) []uint32 {
+	d := []uint32{offset}
+	for i, part := range s.parts {
+		if i < len(s.segments) {
+			d = append(d, uint32(len(part)), segmentIndex[s.segments[i]])
+		} else {
+			// The last part includes the NUL char.
+			d = append(d, uint32(len(part)+1), moSegmentsEnd)
+		}
+	}
+	return d
+}
+
+// sysdepSegmentValue returns the Go equivalent of a system-dependent
+// segment: the "I" flag, which has no equivalent, or a <inttypes.h> macro
+// such as PRIu64 or PRIxLEAST32.
+func sysdepSegmentValue(name string) (string, bool) {
+	if name == "I" {
+		return "", true
+	}
+	if len(name) < 5 || !strings.HasPrefix(name, "PRI") {
+		return "", false
+	}
+	switch strings.TrimPrefix(strings.TrimPrefix(name[4:], "LEAST"), "FAST") {
+	case "8", "16", "32", "64":
+	default:
+		if name[4:] != "MAX" && name[4:] != "PTR" {
+			return "", false
+		}
+	}
+	switch name[3] {
+	case 'd', 'i', 'u':
+		return "d", true
+	case 'o', 'x', 'X':
+		return name[3:4], true
+	}
+	return "", false
+}
@@ -0,0 +1,160 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+// NewTranslator returns a new translator without catalogs. Add catalogs
+// with Add, from the most specific locale to the most generic one.
+func NewTranslator() *Translator {
+	return &Translator{}
+}
+
+// Translator looks up messages in a chain of catalogs, such as the ones
+// for pt_BR, pt and en. Lookups use the first catalog that translates the
+// message; if none does, the untranslated strings are returned, as done by
+// Catalog.
+//
+// Plural forms are selected with the plural rule of the catalog that
+// translates the message, so a message missing in pt_BR and found in pt
+// uses the Plural-Forms of pt.
+type Translator struct {
+	locales  []string
+	catalogs []*Catalog
+}
+
+// Add appends a catalog for the given locale to the end of the chain.
+func (t *Translator) Add(locale string, c *Catalog) {
+	t.locales = append(t.locales, locale)
+	t.catalogs = append(t.catalogs, c)
+}
+
+// Locales returns the locales of the chain, in lookup order.
+func (t *Translator) Locales() []string {
+	return append([]string(nil), t.locales...)
+}
+
+// Singular is like Catalog.Singular, using the first catalog of the chain
+// that translates the message.
+func (t *Translator) Singular(key string, args ...interface{}) string {
+	if text, idx := t.singular(msgKey{id: key}); idx != -1 {
+		return format(text, args)
+	}
+	return format(key, args)
+}
+
+// ContextSingular is like Catalog.ContextSingular, using the first catalog
+// of the chain that translates the message.
+func (t *Translator) ContextSingular(ctxt, key string, args ...interface{}) string {
+	if text, idx := t.singular(msgKey{ctxt, true, key}); idx != -1 {
+		return format(text, args)
+	}
+	return format(key, args)
+}
+
+// Plural is like Catalog.Plural, using the first catalog of the chain that
+// translates the plural form for n.
+func (t *Translator) Plural(key, keyPlural string, n int, args ...interface{}) string {
+	return format(t.pluralForm(msgKey{id: key}, key, keyPlural, n), args)
+}
+
+// ContextPlural is like Catalog.ContextPlural, using the first catalog of
+// the chain that translates the plural form for n.
+func (t *Translator) ContextPlural(ctxt, key, keyPlural string, n int, args ...interface{}) string {
+	return format(t.pluralForm(msgKey{ctxt, true, key}, key, keyPlural, n), args)
+}
+
+// SingularLocale returns the locale of the catalog that answers
+// Singular(key), or "" if no catalog of the chain translates the message.
+func (t *Translator) SingularLocale(key string) string {
+	_, idx := t.singular(msgKey{id: key})
+	return t.locale(idx)
+}
+
+// ContextSingularLocale returns the locale of the catalog that answers
+// ContextSingular(ctxt, key), or "" if no catalog of the chain translates
+// the message.
+func (t *Translator) ContextSingularLocale(ctxt, key string) string {
+	_, idx := t.singular(msgKey{ctxt, true, key})
+	return t.locale(idx)
+}
+
+// PluralLocale returns the locale of the catalog that answers
+// Plural(key, keyPlural, n), or "" if no catalog of the chain translates
+// the plural form for n.
+func (t *Translator) PluralLocale(key string, n int) string {
+	_, idx := t.plural(msgKey{id: key}, n)
+	return t.locale(idx)
+}
+
+// ContextPluralLocale returns the locale of the catalog that answers
+// ContextPlural(ctxt, key, keyPlural, n), or "" if no catalog of the chain
+// translates the plural form for n.
+func (t *Translator) ContextPluralLocale(ctxt, key string, n int) string {
+	_, idx := t.plural(msgKey{ctxt, true, key}, n)
+	return t.locale(idx)
+}
+
+// hasFlag reports whether the message that answers a lookup of key has
+// the given flag. If no catalog translates the message, the first catalog
+// that has it is used.
+func (t *Translator) hasFlag(key msgKey, plural bool, n int, flag string) bool {
+	idx := -1
+	if plural {
+		_, idx = t.plural(key, n)
+	} else {
+		_, idx = t.singular(key)
+	}
+	if idx != -1 {
+		return t.catalogs[idx].hasFlag(key, plural, n, flag)
+	}
+	for _, c := range t.catalogs {
+		if _, ok := c.entry(key); ok {
+			return c.hasFlag(key, plural, n, flag)
+		}
+	}
+	return false
+}
+
+// singular returns the translation stored with the given key and the
+// position in the chain of the catalog that translates it, or -1.
+func (t *Translator) singular(key msgKey) (string, int) {
+	for i, c := range t.catalogs {
+		if text, ok := c.lookupSingular(key); ok {
+			return text, i
+		}
+	}
+	return "", -1
+}
+
+// plural returns the plural translation for n stored with the given key
+// and the position in the chain of the catalog that translates it, or -1.
+func (t *Translator) plural(key msgKey, n int) (string, int) {
+	for i, c := range t.catalogs {
+		if text, ok := c.lookupPlural(key, n); ok {
+			return text, i
+		}
+	}
+	return "", -1
+}
+
+// pluralForm returns the plural translation for n stored with the given
+// key, or one of the fallbacks.
+func (t *Translator) pluralForm(key msgKey, fallback, fallbackPlural string, n int) string {
+	if text, idx := t.plural(key, n); idx != -1 {
+		return text
+	}
+	if n == 1 {
+		return fallback
+	}
+	return fallbackPlural
+}
+
+// locale returns the locale at the given position of the chain, or "" for
+// -1.
+func (t *Translator) locale(idx int) string {
+	if idx == -1 {
+		return ""
+	}
+	return t.locales[idx]
+}
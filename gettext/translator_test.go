@@ -0,0 +1,107 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gettext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var brazilianPoData = `msgid ""
+msgstr ""
+"Language: pt_BR\n"
+"Plural-Forms: nplurals=2; plural=(n > 1);\n"
+
+msgid "Bus"
+msgstr "Ônibus"
+
+msgid "%d train"
+msgid_plural "%d trains"
+msgstr[0] "%d trem"
+msgstr[1] ""
+`
+
+var portuguesePoData = `msgid ""
+msgstr ""
+"Language: pt\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+msgid "Bus"
+msgstr "Autocarro"
+
+msgid "Train"
+msgstr "Comboio"
+
+msgctxt "station"
+msgid "Train"
+msgstr "Estação de comboios"
+
+msgid "%d train"
+msgid_plural "%d trains"
+msgstr[0] "%d comboio"
+msgstr[1] "%d comboios"
+
+msgid "%d ticket"
+msgid_plural "%d tickets"
+msgstr[0] "%d bilhete"
+msgstr[1] "%d bilhetes"
+
+#, fuzzy
+msgid "Car"
+msgstr "Carro"
+`
+
+func TestTranslator(t *testing.T) {
+	b := NewBundle("")
+	for locale, data := range map[string]string{"pt_BR": brazilianPoData, "pt": portuguesePoData} {
+		c := NewCatalog()
+		if err := c.ReadPo(strings.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+		b.AddCatalog(locale, "", c)
+	}
+	tr := b.Translator("", "pt_BR.UTF-8", "en")
+	if locales := tr.Locales(); !reflect.DeepEqual(locales, []string{"pt-BR", "pt"}) {
+		t.Errorf("Expected locales [pt-BR pt], got %v.", locales)
+	}
+	tests := []struct {
+		got, expected string
+	}{
+		{tr.Singular("Bus"), "Ônibus"},
+		{tr.SingularLocale("Bus"), "pt-BR"},
+		{tr.Singular("Train"), "Comboio"},
+		{tr.SingularLocale("Train"), "pt"},
+		{tr.ContextSingular("station", "Train"), "Estação de comboios"},
+		{tr.ContextSingularLocale("station", "Train"), "pt"},
+		{tr.Singular("Car"), "Car"},
+		{tr.SingularLocale("Car"), ""},
+		{tr.Singular("Missing %d", 3), "Missing 3"},
+		// The Brazilian rule selects form 0 for 0, which is translated.
+		{tr.Plural("%d train", "%d trains", 0, 0), "0 trem"},
+		{tr.PluralLocale("%d train", 0), "pt-BR"},
+		// Form 1 is empty in pt_BR, so pt translates it.
+		{tr.Plural("%d train", "%d trains", 2, 2), "2 comboios"},
+		{tr.PluralLocale("%d train", 2), "pt"},
+		// Messages found in pt use the Portuguese rule, which selects
+		// form 1 for 0.
+		{tr.Plural("%d ticket", "%d tickets", 0, 0), "0 bilhetes"},
+		{tr.PluralLocale("%d ticket", 0), "pt"},
+		{tr.ContextPlural("x", "%d train", "%d trains", 2, 2), "2 trains"},
+		{tr.ContextPluralLocale("x", "%d train", 2), ""},
+		{tr.Plural("%d car", "%d cars", 1, 1), "1 car"},
+	}
+	for _, test := range tests {
+		if test.got != test.expected {
+			t.Errorf("Expected %q, got %q.", test.expected, test.got)
+		}
+	}
+
+	// Without catalogs, lookups return the untranslated strings.
+	empty := NewTranslator()
+	if s := empty.Plural("%d train", "%d trains", 2, 2); s != "2 trains" {
+		t.Errorf("Expected %q, got %q.", "2 trains", s)
+	}
+}